@@ -0,0 +1,190 @@
+package core
+
+import (
+	"engo.io/ecs"
+	log "github.com/Sirupsen/logrus"
+	"github.com/engoengine/math/imath"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// How far creatures can see if their data doesn't say otherwise
+const DefaultSightRange = 10
+
+// How far away skills can be heard if their data doesn't say otherwise
+const DefaultSkillNoise = 6
+
+// How far away a creature can be heard when it gets hurt
+const PainNoise = 4
+
+// Noise is a sound made on the map during a turn, which enemies within the
+// radius will hear and come to investigate
+type Noise struct {
+	Location structs.GridPoint
+	Radius   int
+}
+
+// MakeNoise records a noise that enemies will react to at the start of the next enemy turn
+func (ms *MapSystem) MakeNoise(loc structs.GridPoint, radius int) {
+	ms.Noises = append(ms.Noises, Noise{Location: loc, Radius: radius})
+}
+
+// Forgets the noises once the enemies have planned their turn around them, so the ones
+// made while they act are heard next turn instead of being thrown away
+func clearNoises(w *ecs.World) {
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			sys.Noises = nil
+		}
+	}
+}
+
+// Updates what the creature knows about the players based on what it can see and hear.
+// Returns the closest player it can see, if there is one.
+func updateAwareness(creature *structs.Creature, sys *MapSystem) *structs.Creature {
+	loc := structs.PointToGridPoint(creature.Position)
+	sight := creature.SightRange
+	if sight == 0 {
+		sight = DefaultSightRange
+	}
+
+	// Look for the closest player in sight range. Checking the distance first means
+	// creatures far away from everyone don't do any line of sight checks.
+	var seen *structs.Creature
+	closest := sight + 1
+	for _, id := range sortedPlayerIDs(sys) {
+		player := sys.Players[id]
+		if player.Dead {
+			continue
+		}
+		playerLoc := structs.PointToGridPoint(player.Position)
+		if dist := loc.DistanceTo(playerLoc); dist < closest && sys.HasLineOfSight(loc, playerLoc) {
+			closest = dist
+			seen = player
+		}
+	}
+
+	if seen != nil {
+		seenLoc := structs.PointToGridPoint(seen.Position)
+		if creature.Awareness != structs.Hunting {
+			log.Debugf("Creature id %d spotted player at %v", creature.NetworkID, seenLoc)
+			alertRoom(creature, seenLoc, sys)
+		}
+		creature.Awareness = structs.Hunting
+		creature.LastKnownLoc = seenLoc
+		return seen
+	}
+
+	// Listen for noises if we aren't already chasing something
+	if creature.Awareness != structs.Hunting {
+		for _, noise := range sys.Noises {
+			if loc.DistanceTo(noise.Location) <= noise.Radius {
+				creature.Awareness = structs.Suspicious
+				creature.LastKnownLoc = noise.Location
+			}
+		}
+	}
+
+	// Give up once we've reached the spot we were heading for without finding anyone
+	switch creature.Awareness {
+	case structs.Hunting, structs.Suspicious:
+		if reachedGoal(creature, creature.LastKnownLoc, sys) {
+			creature.Awareness = structs.Returning
+		}
+	case structs.Returning:
+		if reachedGoal(creature, creature.Home, sys) {
+			creature.Awareness = structs.Idle
+		}
+	}
+
+	return nil
+}
+
+// Returns whether the creature has got as close to the goal as it's going to. That's either standing
+// on it, standing next to it while someone else is in the way, or there being no way to get there.
+func reachedGoal(creature *structs.Creature, goal structs.GridPoint, sys *MapSystem) bool {
+	loc := structs.PointToGridPoint(creature.Position)
+	if loc == goal {
+		return true
+	}
+	if occupant := sys.GetCreatureAt(goal); occupant != nil && occupant != creature && loc.DistanceTo(goal) <= 1 {
+		return true
+	}
+
+	// Other creatures move around, so only the map itself decides whether the goal can be reached
+	start, end := sys.GetTileAt(loc), sys.GetTileAt(goal)
+	return end == nil || len(GetPath(start, end, sys.Tiles, sys.CreatureLocations, TeamAny)) == 0
+}
+
+// Sets the other enemies in the same room as the creature hunting the given location
+func alertRoom(creature *structs.Creature, target structs.GridPoint, sys *MapSystem) {
+	room := sys.MapInfo.GetRoomAt(structs.PointToGridPoint(creature.Position))
+	if room == nil {
+		return
+	}
+	for _, other := range sys.Creatures {
+		if other.IsPlayerTeam || other == creature || other.Awareness == structs.Hunting {
+			continue
+		}
+		if room.Contains(structs.PointToGridPoint(other.Position)) {
+			log.Debugf("Creature id %d alerted by creature id %d", other.NetworkID, creature.NetworkID)
+			other.Awareness = structs.Hunting
+			other.LastKnownLoc = target
+		}
+	}
+}
+
+// HasLineOfSight returns whether creatures can see from one point to the other
+func (ms *MapSystem) HasLineOfSight(a, b structs.GridPoint) bool {
+	return ms.hasClearLine(a, b, ms.BlocksSight)
+}
+
+// Returns whether none of the tiles in between the two points are blocked, using
+// Bresenham's line algorithm to find them. The points themselves aren't checked.
+func (ms *MapSystem) hasClearLine(a, b structs.GridPoint, blocked func(structs.GridPoint) bool) bool {
+	dx := imath.Abs(b.X - a.X)
+	dy := -imath.Abs(b.Y - a.Y)
+	stepX, stepY := 1, 1
+	if a.X > b.X {
+		stepX = -1
+	}
+	if a.Y > b.Y {
+		stepY = -1
+	}
+
+	err := dx + dy
+	current := a
+	for current != b {
+		if current != a && blocked(current) {
+			return false
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			current.X += stepX
+		}
+		if e2 <= dx {
+			err += dx
+			current.Y += stepY
+		}
+	}
+	return true
+}
+
+// BlocksSight returns whether creatures are unable to see through the given point
+func (ms *MapSystem) BlocksSight(point structs.GridPoint) bool {
+	if !ms.InBounds(point) {
+		return true
+	}
+	tile := ms.GetTileAt(point)
+	return tile == nil || tile.BlocksSight
+}
+
+// BlocksLight returns whether light is unable to shine through the given point
+func (ms *MapSystem) BlocksLight(point structs.GridPoint) bool {
+	if !ms.InBounds(point) {
+		return true
+	}
+	tile := ms.GetTileAt(point)
+	return tile == nil || tile.BlocksLight
+}
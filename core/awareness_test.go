@@ -0,0 +1,86 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestAwarenessGivesUp(t *testing.T) {
+	w, ms := newTestWorld(
+		"########",
+		"#....#.#",
+		"########",
+	)
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 1, Y: 1})
+	AddCreature(w, skeleton)
+	other := structs.NewCreature("Skeleton", structs.GridPoint{X: 2, Y: 1})
+	AddCreature(w, other)
+
+	cases := []struct {
+		awareness structs.AwarenessState
+		goal      structs.GridPoint
+		expected  structs.AwarenessState
+	}{
+		// Someone else is standing where it was heading
+		{structs.Hunting, structs.GridPoint{X: 2, Y: 1}, structs.Returning},
+		{structs.Returning, structs.GridPoint{X: 2, Y: 1}, structs.Idle},
+		// There's no way through the wall
+		{structs.Suspicious, structs.GridPoint{X: 6, Y: 1}, structs.Returning},
+		{structs.Returning, structs.GridPoint{X: 6, Y: 1}, structs.Idle},
+		// Still on its way
+		{structs.Hunting, structs.GridPoint{X: 4, Y: 1}, structs.Hunting},
+		{structs.Returning, structs.GridPoint{X: 4, Y: 1}, structs.Returning},
+	}
+
+	for i, c := range cases {
+		skeleton.Awareness = c.awareness
+		skeleton.LastKnownLoc = c.goal
+		skeleton.Home = c.goal
+		updateAwareness(skeleton, ms)
+		if skeleton.Awareness != c.expected {
+			t.Errorf("bad: case %d: expected %v, got %v", i, c.expected, skeleton.Awareness)
+		}
+	}
+}
+
+func TestEnemyOpensDoor(t *testing.T) {
+	w, ms := newTestWorld(
+		"#######",
+		"#..#..#",
+		"#..+..#",
+		"#######",
+	)
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 1, Y: 2})
+	skeleton.Awareness = structs.Suspicious
+	skeleton.LastKnownLoc = structs.GridPoint{X: 5, Y: 2}
+	AddCreature(w, skeleton)
+
+	turnOrder := []int{int(skeleton.NetworkID)}
+	actions := ProcessCreatureTurn(skeleton.NetworkID, ms, NewGroupPlan(turnOrder, ms))
+	if len(actions) != 2 {
+		t.Fatalf("bad: %#v", actions)
+	}
+	move, ok := actions[0].(*Move)
+	if !ok || pathEnd(skeleton, move.Path) != (structs.GridPoint{X: 2, Y: 2}) {
+		t.Fatalf("bad: %#v", actions[0])
+	}
+	toggle, ok := actions[1].(*ToggleDoor)
+	if !ok || toggle.Location != (structs.GridPoint{X: 3, Y: 2}) {
+		t.Fatalf("bad: %#v", actions[1])
+	}
+
+	// Once it's there, it opens the door and can walk through next turn
+	front := structs.GridPoint{X: 2, Y: 2}
+	ms.CreatureLocations[1][2] = nil
+	skeleton.Position = front.ToPixels()
+	ms.CreatureLocations[front.X][front.Y] = skeleton
+	toggle.Process(w, 0)
+	if !ms.GetTileAt(toggle.Location).Walkable {
+		t.Fatal("bad: door is still closed")
+	}
+	actions = ProcessCreatureTurn(skeleton.NetworkID, ms, NewGroupPlan(turnOrder, ms))
+	if move, ok := actions[0].(*Move); len(actions) != 1 || !ok || pathEnd(skeleton, move.Path) != skeleton.LastKnownLoc {
+		t.Fatalf("bad: %#v", actions)
+	}
+}
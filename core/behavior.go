@@ -0,0 +1,353 @@
+package core
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/engoengine/math/imath"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// Behavior is the interface for the AI profiles that control enemy creatures. TakeTurn
+// is given the creature, the player it's targeting and the plan for the enemy turn, and
+// returns the events for the creature's actions this turn.
+type Behavior interface {
+	TakeTurn(creature *structs.Creature, target *structs.Creature, sys *MapSystem, plan *GroupPlan) []Event
+}
+
+const DefaultBehavior = "brute"
+
+// The behavior profiles that can be set for a creature in the data file
+var behaviors = map[string]Behavior{
+	"brute":   &BruteBehavior{},
+	"ranged":  &RangedBehavior{},
+	"caster":  &CasterBehavior{},
+	"coward":  &CowardBehavior{FleeThreshold: 0.3, Fallback: &BruteBehavior{}},
+	"support": &SupportBehavior{Fallback: &RangedBehavior{}},
+}
+
+// RegisterBehavior adds a behavior profile that creatures can use, replacing any existing one with the same name
+func RegisterBehavior(name string, behavior Behavior) {
+	behaviors[name] = behavior
+}
+
+// GetBehavior returns the behavior profile with the given name, or the default if it doesn't exist
+func GetBehavior(name string) Behavior {
+	if behavior, ok := behaviors[name]; ok {
+		return behavior
+	}
+	if name != "" {
+		log.Warnf("Unknown creature behavior '%s', using '%s'", name, DefaultBehavior)
+	}
+	return behaviors[DefaultBehavior]
+}
+
+// BruteBehavior walks up to the target and hits it with the strongest skill it can use
+type BruteBehavior struct{}
+
+func (b *BruteBehavior) TakeTurn(creature *structs.Creature, target *structs.Creature, sys *MapSystem, plan *GroupPlan) []Event {
+	// Head for the tile next to a target we've been assigned by the group plan, so we don't
+	// end up queueing behind other enemies going for the same spot
+	var path []structs.GridPoint
+	if slot, ok := plan.AttackSlot(creature, sys); ok {
+		start := sys.GetTileAt(structs.PointToGridPoint(creature.Position))
+		path = GetPath(start, sys.GetTileAt(slot.Location), sys.Tiles, sys.CreatureLocations, TeamEnemy)
+		path = LimitPath(path, creature.Stats().Movement, sys.Tiles)
+		path = plan.TrimPath(creature, path, sys)
+	} else {
+		path = pathTowards(creature, structs.PointToGridPoint(target.Position), sys, plan)
+	}
+	return actionsFrom(creature, path, plan, bestSkillFrom(creature, pathEnd(creature, path), sys, false))
+}
+
+// RangedBehavior tries to stay at the maximum range of its skills from the target
+// while attacking, backing off if the target gets too close
+type RangedBehavior struct{}
+
+func (b *RangedBehavior) TakeTurn(creature *structs.Creature, target *structs.Creature, sys *MapSystem, plan *GroupPlan) []Event {
+	desiredRange := 1
+	for _, name := range creature.GetSkills() {
+		skill := structs.GetSkillData(name)
+		if !skill.HasTag(structs.HealTag) && skill.MaxRange > desiredRange {
+			desiredRange = skill.MaxRange
+		}
+	}
+
+	targetLoc := structs.PointToGridPoint(target.Position)
+	var path []structs.GridPoint
+	bestDiff := 9999
+	for _, reachable := range reachableTiles(creature, sys, plan) {
+		loc := reachable[len(reachable)-1]
+		diff := imath.Abs(loc.DistanceTo(targetLoc) - desiredRange)
+		if diff < bestDiff {
+			bestDiff = diff
+			path = reachable
+		}
+	}
+
+	return actionsFrom(creature, path, plan, bestSkillFrom(creature, pathEnd(creature, path), sys, false))
+}
+
+// CasterBehavior looks at every tile it can move to and every skill it has, and
+// picks the combination that does the most damage, such as placing an area of effect
+// to catch multiple players. If nothing is in reach, it moves towards the target.
+type CasterBehavior struct{}
+
+func (b *CasterBehavior) TakeTurn(creature *structs.Creature, target *structs.Creature, sys *MapSystem, plan *GroupPlan) []Event {
+	var best *skillOption
+	var path []structs.GridPoint
+	for _, reachable := range reachableTiles(creature, sys, plan) {
+		option := bestSkillFrom(creature, reachable[len(reachable)-1], sys, false)
+		if option != nil && (best == nil || option.score > best.score) {
+			best = option
+			path = reachable
+		}
+	}
+
+	if best == nil {
+		path = pathTowards(creature, structs.PointToGridPoint(target.Position), sys, plan)
+	}
+	return actionsFrom(creature, path, plan, best)
+}
+
+// CowardBehavior runs as far away from the players as it can once its life drops
+// below FleeThreshold (as a fraction of its max life), and otherwise acts like its
+// Fallback behavior.
+type CowardBehavior struct {
+	FleeThreshold float64
+	Fallback      Behavior
+}
+
+func (b *CowardBehavior) TakeTurn(creature *structs.Creature, target *structs.Creature, sys *MapSystem, plan *GroupPlan) []Event {
+	if float64(creature.Life) >= b.FleeThreshold*float64(creature.Stats().MaxLife) {
+		return b.Fallback.TakeTurn(creature, target, sys, plan)
+	}
+
+	var path []structs.GridPoint
+	furthest := -1
+	for _, reachable := range reachableTiles(creature, sys, plan) {
+		loc := reachable[len(reachable)-1]
+		closest := 9999
+		for _, player := range sys.Players {
+			if !player.Dead {
+				closest = imath.Min(closest, loc.DistanceTo(structs.PointToGridPoint(player.Position)))
+			}
+		}
+		if closest > furthest {
+			furthest = closest
+			path = reachable
+		}
+	}
+
+	return actionsFrom(creature, path, plan, nil)
+}
+
+// SupportBehavior heals injured allies when it can, and otherwise acts like its Fallback behavior
+type SupportBehavior struct {
+	Fallback Behavior
+}
+
+func (b *SupportBehavior) TakeTurn(creature *structs.Creature, target *structs.Creature, sys *MapSystem, plan *GroupPlan) []Event {
+	var best *skillOption
+	var path []structs.GridPoint
+	for _, reachable := range reachableTiles(creature, sys, plan) {
+		option := bestSkillFrom(creature, reachable[len(reachable)-1], sys, true)
+		if option != nil && (best == nil || option.score > best.score) {
+			best = option
+			path = reachable
+		}
+	}
+
+	if best == nil {
+		return b.Fallback.TakeTurn(creature, target, sys, plan)
+	}
+	return actionsFrom(creature, path, plan, best)
+}
+
+// A potential use of a skill, scored by how useful it would be
+type skillOption struct {
+	skill  string
+	target structs.SkillTarget
+	score  int
+}
+
+// Returns the paths to all the tiles the creature can move to this turn, including staying still,
+// leaving out tiles other enemies have already reserved and paths over hazards
+func reachableTiles(creature *structs.Creature, sys *MapSystem, plan *GroupPlan) [][]structs.GridPoint {
+	start := sys.GetTileAt(structs.PointToGridPoint(creature.Position))
+	var paths [][]structs.GridPoint
+	for _, path := range GetReachableTiles(start, creature.Stats().Movement, sys.Tiles, sys.CreatureLocations, TeamEnemy) {
+		if len(path) == 1 || (!plan.IsReserved(creature.NetworkID, path[len(path)-1]) && !crossesHazard(path, sys)) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Returns whether the path steps onto any hazards after its start
+func crossesHazard(path []structs.GridPoint, sys *MapSystem) bool {
+	for _, point := range path[1:] {
+		if knowsHazard(sys.GetTileAt(point), TeamEnemy) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the path towards the nearest free tile next to the target, cut short to the creature's movement
+func pathTowards(creature *structs.Creature, targetLoc structs.GridPoint, sys *MapSystem, plan *GroupPlan) []structs.GridPoint {
+	creatureTile := sys.GetTileAt(structs.PointToGridPoint(creature.Position))
+	neighbors := getNeighbors(sys.GetTileAt(targetLoc), sys.Tiles, isWalkable, func(x, y int) bool { return true })
+	var path []structs.GridPoint
+	shortestPath := 9999
+	for _, neighbor := range neighbors {
+		if occupant := sys.GetCreatureAt(neighbor.GridPoint); (occupant != nil && occupant != creature) || plan.IsReserved(creature.NetworkID, neighbor.GridPoint) {
+			continue
+		}
+		currentPath := GetPath(creatureTile, neighbor, sys.Tiles, sys.CreatureLocations, TeamEnemy)
+		if cost := PathCost(currentPath, sys.Tiles); len(currentPath) > 0 && cost < shortestPath {
+			path = currentPath
+			shortestPath = cost
+		}
+	}
+
+	path = LimitPath(path, creature.Stats().Movement, sys.Tiles)
+	return plan.TrimPath(creature, path, sys)
+}
+
+// Returns the path towards the given location, cut short to the creature's movement. If someone
+// else is standing there, the path leads next to them instead.
+func pathTo(creature *structs.Creature, loc structs.GridPoint, sys *MapSystem, plan *GroupPlan) []structs.GridPoint {
+	start := sys.GetTileAt(structs.PointToGridPoint(creature.Position))
+	goal := sys.GetTileAt(loc)
+	if goal == nil {
+		return nil
+	}
+	if occupant := sys.GetCreatureAt(loc); occupant != nil && occupant != creature {
+		return pathTowards(creature, loc, sys, plan)
+	}
+	path := GetPath(start, goal, sys.Tiles, sys.CreatureLocations, TeamEnemy)
+	path = LimitPath(path, creature.Stats().Movement, sys.Tiles)
+	return plan.TrimPath(creature, path, sys)
+}
+
+// Returns where the creature will be standing after following the path
+func pathEnd(creature *structs.Creature, path []structs.GridPoint) structs.GridPoint {
+	if len(path) > 0 {
+		return path[len(path)-1]
+	}
+	return structs.PointToGridPoint(creature.Position)
+}
+
+// Builds the events for moving along the path (if it goes anywhere) and then using the skill (if there
+// is one), reserving the end of the path in the plan
+func actionsFrom(creature *structs.Creature, path []structs.GridPoint, plan *GroupPlan, option *skillOption) []Event {
+	var actions []Event
+	plan.Reserve(creature.NetworkID, pathEnd(creature, path))
+	if len(path) > 1 {
+		actions = append(actions, &Move{
+			Id:   creature.NetworkID,
+			Path: path,
+		})
+	}
+	if option != nil {
+		actions = append(actions, &UseSkill{
+			SkillName: option.skill,
+			Source:    creature.NetworkID,
+			Target:    option.target,
+		})
+	} else if door, ok := plan.OpenDoor(creature.NetworkID, pathEnd(creature, path)); ok {
+		actions = append(actions, &ToggleDoor{
+			CreatureId: creature.NetworkID,
+			Location:   door,
+		})
+	}
+	return actions
+}
+
+// Finds the most useful skill the creature could use from the given location, or nil if no skill
+// would do anything useful. If healOnly is set, only skills that heal are considered.
+func bestSkillFrom(creature *structs.Creature, loc structs.GridPoint, sys *MapSystem, healOnly bool) *skillOption {
+	var best *skillOption
+	for _, name := range creature.GetSkills() {
+		skill := structs.GetSkillData(name)
+		if healOnly && !skill.HasTag(structs.HealTag) {
+			continue
+		}
+
+		for _, target := range skillTargetCandidates(skill, creature, sys) {
+			if !CanUseSkill(name, sys, creature.NetworkID, target, &loc) {
+				continue
+			}
+			score := scoreSkill(skill, creature, target, loc, sys)
+			if score > 0 && (best == nil || score > best.score) {
+				best = &skillOption{skill: name, target: target, score: score}
+			}
+		}
+	}
+	return best
+}
+
+// Returns the targets worth considering for a skill: the creatures it could hit, and for
+// skills that target the ground, the tiles around them as well.
+func skillTargetCandidates(skill structs.Skill, creature *structs.Creature, sys *MapSystem) []structs.SkillTarget {
+	var targets []structs.SkillTarget
+	for _, other := range sys.Creatures {
+		if other.Dead {
+			continue
+		}
+		// Heals go on allies, everything else goes on enemies
+		if (other.IsPlayerTeam == creature.IsPlayerTeam) != skill.HasTag(structs.HealTag) {
+			continue
+		}
+
+		loc := structs.PointToGridPoint(other.Position)
+		if !skill.TargetsGround {
+			targets = append(targets, structs.SkillTarget{ID: other.NetworkID})
+			continue
+		}
+		for _, offset := range []structs.GridPoint{{0, 0}, {1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			point := structs.GridPoint{X: loc.X + offset.X, Y: loc.Y + offset.Y}
+			if sys.InBounds(point) && sys.GetTileAt(point) != nil {
+				targets = append(targets, structs.SkillTarget{Location: point})
+			}
+		}
+	}
+	return targets
+}
+
+// Estimates how useful a skill would be: the damage done to enemies minus the damage done
+// to allies, or for heal skills, the amount of missing life restored to allies.
+func scoreSkill(skill structs.Skill, creature *structs.Creature, target structs.SkillTarget, loc structs.GridPoint, sys *MapSystem) int {
+	amount := GetSkillDamage(skill, creature)
+	score := 0
+	seen := make(map[structs.GridPoint]bool)
+	for _, point := range GetSkillTargets(skill.Name, sys, creature.NetworkID, target, &loc) {
+		if seen[point] || !sys.InBounds(point) {
+			continue
+		}
+		seen[point] = true
+
+		hit := sys.GetCreatureAt(point)
+		if point == loc {
+			hit = creature
+		} else if hit == creature {
+			// We won't be standing here anymore once we've moved
+			continue
+		}
+		if hit == nil {
+			continue
+		}
+
+		ally := hit.IsPlayerTeam == creature.IsPlayerTeam
+		switch {
+		case skill.HasTag(structs.HealTag) && ally:
+			score += imath.Min(amount, hit.Stats().MaxLife-hit.Life)
+		case skill.HasTag(structs.HealTag):
+			score -= amount
+		case ally:
+			score -= amount
+		default:
+			score += imath.Min(amount, hit.Life)
+		}
+	}
+	return score
+}
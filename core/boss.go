@@ -0,0 +1,54 @@
+package core
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// How far from a boss its summons can appear
+const SummonRange = 4
+
+// CheckBossPhases starts any phases of a boss fight whose life threshold the creature
+// has dropped below. It runs on every client after damage is dealt, so the summons
+// get the same NetworkIDs everywhere.
+func CheckBossPhases(creature *structs.Creature, sys *MapSystem) {
+	for creature.Phase < len(creature.Phases) {
+		phase := creature.Phases[creature.Phase]
+		if creature.Life*100 > creature.Stats().MaxLife*phase.LifePercent {
+			return
+		}
+		creature.Phase++
+		startBossPhase(creature, phase, sys)
+	}
+}
+
+func startBossPhase(creature *structs.Creature, phase structs.BossPhase, sys *MapSystem) {
+	log.Infof("%s enters phase: %s", creature.Name, phase.Name)
+
+	if phase.Behavior != "" {
+		creature.Behavior = phase.Behavior
+	}
+	creature.InnateSkills = append(creature.InnateSkills, phase.Skills...)
+
+	// The phase's bonuses are added on top of the boss's stats from now on (see Creature.Stats),
+	// and any extra life or stamina comes with them
+	creature.InvalidateStats()
+	creature.Life += phase.Bonuses.MaxLife
+	creature.Stamina += phase.Bonuses.MaxStamina
+
+	// Put the summons on the free tiles closest to the boss
+	loc := structs.PointToGridPoint(creature.Position)
+	spots := GetReachableTiles(sys.GetTileAt(loc), SummonRange, sys.Tiles, sys.CreatureLocations, TeamAny)
+	for i, name := range phase.Summons {
+		// The first spot is the boss's own tile
+		if i+1 >= len(spots) {
+			log.Warnf("No room to summon %s", name)
+			break
+		}
+		spot := spots[i+1]
+		summon := structs.NewCreature(name, spot[len(spot)-1])
+		summon.Awareness = creature.Awareness
+		summon.LastKnownLoc = creature.LastKnownLoc
+		AddCreature(sys.world, summon)
+	}
+}
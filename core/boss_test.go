@@ -0,0 +1,43 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestBossPhaseBonuses(t *testing.T) {
+	w, ms := newTestWorld(
+		"#######",
+		"#.....#",
+		"#.....#",
+		"#.....#",
+		"#######",
+	)
+	king := structs.NewCreature("Skeleton King", structs.GridPoint{X: 3, Y: 2})
+	AddCreature(w, king)
+	base := king.Intelligence
+	stats := king.Stats()
+
+	// Dropping past both thresholds at once goes through both phases, and the cached stats
+	// pick up the bonuses of the last one
+	king.Life = stats.MaxLife / 4
+	CheckBossPhases(king, ms)
+	bonus := king.Phases[1].Bonuses
+	if king.Phase != 2 || king.Stats().Intelligence != base+bonus.Intelligence || king.Intelligence != base {
+		t.Fatalf("bad: phase %d, int %d with base %d", king.Phase, king.Stats().Intelligence, king.Intelligence)
+	}
+	if king.Stats().StaminaRegen != stats.StaminaRegen+bonus.StaminaRegen {
+		t.Fatalf("bad: regen %d", king.Stats().StaminaRegen)
+	}
+
+	summons := 0
+	for _, creature := range ms.Creatures {
+		if creature != king {
+			summons++
+		}
+	}
+	if summons != len(king.Phases[0].Summons)+len(king.Phases[1].Summons) {
+		t.Fatalf("bad: %d summons", summons)
+	}
+}
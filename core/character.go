@@ -0,0 +1,69 @@
+package core
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// JoinRequest is what a player sends when joining a game: the class to play for a new
+// character, and their saved character if they have one
+type JoinRequest struct {
+	Name      string
+	Class     string
+	Character *structs.CharacterSave
+}
+
+// NewJoinRequest loads the named character from the local save directory, if there is one
+func NewJoinRequest(name string, class string) JoinRequest {
+	join := JoinRequest{Name: name, Class: class}
+	if name == "" {
+		return join
+	}
+
+	character, err := structs.LoadCharacter(structs.SaveDir, name)
+	if err != nil {
+		log.Errorf("Error loading character, starting a new one: %s", err)
+	} else if character != nil {
+		log.Infof("Loaded %s '%s' with %d experience", character.Class, name, character.Experience)
+		join.Character = character
+	}
+	return join
+}
+
+// Checks a joining player's class and saved character against the data file, upgrading the
+// character if it's from an older version. Unknown classes fall back to the first class, so a
+// typo doesn't stop the game from starting, and characters that don't check out are replaced
+// with new ones.
+func (join *JoinRequest) validate(id PlayerID) {
+	if structs.GetClass(join.Class).Name == "" && len(structs.GetClasses()) > 0 {
+		fallback := structs.GetClasses()[0].Name
+		log.Warnf("[server] Player %d asked for unknown class '%s', playing as %s instead", id, join.Class, fallback)
+		join.Class = fallback
+	}
+	if join.Character == nil {
+		return
+	}
+	for _, change := range join.Character.Upgrade() {
+		log.Warnf("[server] Upgrading '%s': %s", join.Name, change)
+	}
+	if err := join.Character.Validate(); err != nil {
+		log.Errorf("[server] Rejecting saved character, starting a new one: %s", err)
+		join.Character = nil
+	} else if join.Character.Name != join.Name {
+		log.Errorf("[server] Rejecting saved character '%s' sent for '%s'", join.Character.Name, join.Name)
+		join.Character = nil
+	}
+}
+
+// SaveCharacter writes the local player's character to the save directory, if they're
+// playing a named character and still alive
+func (input *InputSystem) SaveCharacter() {
+	if input.player == nil || input.player.Character == "" || input.player.Dead {
+		return
+	}
+	if err := structs.SaveCharacter(structs.SaveDir, input.player); err != nil {
+		log.Errorf("Error saving character: %s", err)
+		return
+	}
+	log.Infof("Saved character '%s'", input.player.Character)
+}
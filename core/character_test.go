@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestJoinRequestValidate(t *testing.T) {
+	// Unknown classes fall back to the first class in the data file
+	join := JoinRequest{Class: "Bard"}
+	join.validate(1)
+	if join.Class != structs.GetClasses()[0].Name {
+		t.Fatalf("bad: %s", join.Class)
+	}
+	join = JoinRequest{Class: "Mage"}
+	join.validate(1)
+	if join.Class != "Mage" {
+		t.Fatalf("bad: %s", join.Class)
+	}
+
+	// Saved characters have to be sent under their own name
+	player := structs.NewPlayer("Rogue", structs.GridPoint{})
+	player.Character = "Bob"
+	save := structs.NewCharacterSave(player)
+	join = JoinRequest{Name: "Alice", Class: "Rogue", Character: save}
+	join.validate(1)
+	if join.Character != nil {
+		t.Fatal("bad: kept a character sent under another name")
+	}
+	join = JoinRequest{Name: "Bob", Class: "Rogue", Character: save}
+	join.validate(1)
+	if join.Character != save {
+		t.Fatal("bad: rejected a valid character")
+	}
+}
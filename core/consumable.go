@@ -0,0 +1,87 @@
+package core
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/engoengine/math/imath"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// CanUseItem returns whether the creature can use the consumable in the given inventory slot
+// on the target. Targeted items need to be aimed at somewhere in range and in sight, and
+// teleports need an empty tile to land on. sourceLoc overrides where the creature is, if set.
+func CanUseItem(sys *MapSystem, creatureID structs.NetworkID, slot int, target structs.SkillTarget, sourceLoc *structs.GridPoint) bool {
+	creature, ok := sys.Creatures[creatureID]
+	if !ok {
+		return false
+	}
+	item := creature.InventoryItem(slot)
+	if item == nil || !item.IsConsumable() || item.Charges < 1 {
+		return false
+	}
+
+	use := item.Use
+	if !use.IsTargeted() {
+		return true
+	}
+
+	source := structs.PointToGridPoint(creature.Position)
+	if sourceLoc != nil {
+		source = *sourceLoc
+	}
+	if use.Skill != "" {
+		return canTargetSkill(use.Skill, sys, creatureID, target, &source)
+	}
+
+	loc := target.Location
+	if !sys.InBounds(loc) || source.DistanceTo(loc) > use.Range || !sys.HasLineOfSight(source, loc) {
+		return false
+	}
+	return isWalkable(sys.GetTileAt(loc)) && sys.GetCreatureAt(loc) == nil
+}
+
+// Applies the effects of the consumable to the creature using it, then uses up a charge. Once
+// it's out of charges the next item on the stack gets used, or the item's removed if it was
+// the last one.
+func useItem(sys *MapSystem, creature *structs.Creature, slot int, target structs.SkillTarget) {
+	item := creature.Inventory[slot]
+	use := item.Use
+	log.Infof("Creature id %d used %s", creature.NetworkID, item.DisplayName())
+
+	if use.Heal > 0 {
+		creature.Life = imath.Min(creature.Life+use.Heal, creature.Stats().MaxLife)
+	}
+	if use.Stamina > 0 {
+		creature.Stamina = imath.Min(creature.Stamina+use.Stamina, creature.Stats().MaxStamina)
+	}
+	if use.Status.Turns > 0 {
+		creature.AddStatus(use.Status)
+	}
+
+	item.Charges--
+	if item.Charges < 1 && item.Count > 1 {
+		item.Count--
+		item.Charges = item.MaxCharges()
+	} else if item.Charges < 1 {
+		creature.Inventory[slot] = nil
+		sys.RemoveItem(item)
+	}
+
+	if use.Teleport {
+		from := structs.PointToGridPoint(creature.Position)
+		sys.CreatureLocations[from.X][from.Y] = nil
+		sys.CreatureLocations[target.Location.X][target.Location.Y] = creature
+		creature.Position = target.Location.ToPixels()
+		for _, system := range sys.world.Systems() {
+			switch s := system.(type) {
+			case *LightSystem:
+				s.needsUpdate = true
+			}
+		}
+		if sys.TriggerHazard(creature, sys.GetTileAt(target.Location), false) && creature.IsPlayerTeam {
+			sys.DetectHazards(creature)
+		}
+	}
+	if use.Skill != "" && !creature.Dead {
+		castSkill(use.Skill, sys, creature.NetworkID, target)
+	}
+}
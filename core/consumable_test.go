@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestUseTeleportItem(t *testing.T) {
+	w, ms := newTestWorld(
+		"########",
+		"#..#...#",
+		"#......#",
+		"########",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+	AddCreature(w, structs.NewCreature("Skeleton", structs.GridPoint{X: 2, Y: 2}))
+	scroll := structs.NewItem("Scroll of Blinking", structs.GridPoint{X: 1, Y: 1})
+	GiveItem(w, player, scroll)
+	if player.Inventory[0] != scroll {
+		t.Fatalf("bad: %v", player.Inventory)
+	}
+
+	// Teleports have to land on an empty tile the player can see
+	for _, loc := range []structs.GridPoint{{X: 3, Y: 1}, {X: 4, Y: 1}, {X: 2, Y: 2}} {
+		if CanUseItem(ms, player.NetworkID, 0, structs.SkillTarget{Location: loc}, nil) {
+			t.Errorf("bad: could teleport to %v", loc)
+		}
+	}
+
+	target := structs.GridPoint{X: 5, Y: 2}
+	(&UseItem{InventorySlot: 0, CreatureId: player.NetworkID, Target: structs.SkillTarget{Location: target}}).Process(w, 0)
+	if loc := structs.PointToGridPoint(player.Position); loc != target || ms.GetCreatureAt(target) != player || ms.GetCreatureAt(structs.GridPoint{X: 1, Y: 1}) != nil {
+		t.Fatalf("bad: player at %v", loc)
+	}
+	if _, ok := ms.Items[scroll.NetworkID]; ok || player.Inventory[0] != nil {
+		t.Fatal("bad: scroll wasn't used up")
+	}
+}
+
+func TestUseSkillItem(t *testing.T) {
+	w, ms := newTestWorld(
+		"########",
+		"#......#",
+		"########",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 4, Y: 1})
+	AddCreature(w, skeleton)
+	bomb := structs.NewItem("Fire Bomb", structs.GridPoint{X: 1, Y: 1})
+	GiveItem(w, player, bomb)
+
+	// The item's skill decides the range
+	if CanUseItem(ms, player.NetworkID, 0, structs.SkillTarget{Location: structs.GridPoint{X: 6, Y: 1}}, nil) {
+		t.Fatal("bad: threw the bomb out of range")
+	}
+
+	// Each use casts the skill and takes a charge, leaving the item until it runs out
+	life, charges := skeleton.Life, bomb.Charges
+	use := &UseItem{InventorySlot: 0, CreatureId: player.NetworkID, Target: structs.SkillTarget{Location: structs.GridPoint{X: 4, Y: 1}}}
+	use.Process(w, 0)
+	if skeleton.Life >= life || bomb.Charges != charges-1 || player.Inventory[0] != bomb {
+		t.Fatalf("bad: skeleton life %d, %d charges", skeleton.Life, bomb.Charges)
+	}
+	for bomb.Charges > 0 {
+		use.Process(w, 0)
+	}
+	if player.Inventory[0] != nil || CanUseItem(ms, player.NetworkID, 0, use.Target, nil) {
+		t.Fatal("bad: bomb wasn't used up")
+	}
+}
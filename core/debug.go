@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/kyhavlov/go-dnd/mapgen"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// The characters used for things drawn on top of the tiles in a map dump
+const (
+	pathChar   = '*'
+	itemChar   = '!'
+	allyChar   = 'A'
+	enemyChar  = 'E'
+	noTileChar = ' '
+)
+
+// Dump draws the map as text, for debugging pathing and spawning problems without the game
+// window. Players are drawn as their player ID, other creatures by their team, items on
+// the ground as '!' and the given path (such as a planned move) as '*'. Everything on the
+// map is listed below the grid, followed by the light level of each tile from 0 to 9 if
+// lights is set.
+func (ms *MapSystem) Dump(path []structs.GridPoint, lights bool) string {
+	width, height := ms.MapWidth(), ms.MapHeight()
+	grid := make([][]byte, height)
+	for y := range grid {
+		grid[y] = bytes.Repeat([]byte{noTileChar}, width)
+	}
+
+	for x, column := range ms.Tiles {
+		for y, tile := range column {
+			if tile != nil {
+				grid[y][x] = mapgen.TileChar(tile.Name)
+			}
+		}
+	}
+	for _, point := range path {
+		if ms.InBounds(point) {
+			grid[point.Y][point.X] = pathChar
+		}
+	}
+
+	var out bytes.Buffer
+	var lines []string
+	for x, column := range ms.ItemLocations {
+		for y, items := range column {
+			for _, item := range items {
+				grid[y][x] = itemChar
+				lines = append(lines, fmt.Sprintf("%c %s at %v", itemChar, item.Name, structs.GridPoint{X: x, Y: y}))
+			}
+		}
+	}
+
+	players := make(map[*structs.Creature]PlayerID)
+	for id, player := range ms.Players {
+		players[player] = id
+	}
+	for x, column := range ms.CreatureLocations {
+		for y, creature := range column {
+			if creature == nil {
+				continue
+			}
+			char := byte(enemyChar)
+			if id, ok := players[creature]; ok {
+				char = '0' + byte(id%10)
+			} else if creature.IsPlayerTeam {
+				char = allyChar
+			}
+			grid[y][x] = char
+			lines = append(lines, fmt.Sprintf("%c %s at %v, life %d/%d, stamina %d", char, creature.Name, structs.GridPoint{X: x, Y: y},
+				creature.Life, creature.Stats().MaxLife, creature.Stamina))
+		}
+	}
+
+	fmt.Fprintf(&out, "size %dx%d", width, height)
+	if ms.MapInfo != nil {
+		fmt.Fprintf(&out, ", floor %d, seed %d", ms.MapInfo.Floor, ms.MapInfo.Seed)
+	}
+	out.WriteByte('\n')
+	for _, row := range grid {
+		out.Write(bytes.TrimRight(row, " "))
+		out.WriteByte('\n')
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	if lights {
+		out.WriteString("light levels:\n")
+		for y := 0; y < height; y++ {
+			row := bytes.Repeat([]byte{noTileChar}, width)
+			for x := 0; x < width; x++ {
+				if tile := ms.Tiles[x][y]; tile != nil && tile.Color != nil {
+					_, _, _, alpha := tile.Color.RGBA()
+					row[x] = '0' + byte(alpha*9/0xffff)
+				}
+			}
+			out.Write(bytes.TrimRight(row, " "))
+			out.WriteByte('\n')
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestDump(t *testing.T) {
+	w, ms := newTestWorld(
+		"#######",
+		"#..#..#",
+		"#..+.>#",
+		"#######",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+	player.Life = 20
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 4, Y: 1})
+	AddCreature(w, skeleton)
+	AddItem(w, structs.NewItem("Leather Armor", structs.GridPoint{X: 2, Y: 2}))
+	path := []structs.GridPoint{{X: 1, Y: 1}, {X: 1, Y: 2}}
+
+	expected := "size 7x4, floor 1, seed 1\n" +
+		"#######\n" +
+		"#0.#E.#\n" +
+		"#*!+.>#\n" +
+		"#######\n" +
+		"! Leather Armor at {2 2}\n" +
+		fmt.Sprintf("0 Player at {1 1}, life 20/%d, stamina %d\n", player.Stats().MaxLife, player.Stamina) +
+		fmt.Sprintf("E Skeleton at {4 1}, life %d/%d, stamina %d\n", skeleton.Life, skeleton.Stats().MaxLife, skeleton.Stamina)
+	if actual := ms.Dump(path, false); actual != expected {
+		t.Fatalf("bad:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
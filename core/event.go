@@ -5,10 +5,14 @@ import (
 	"engo.io/ecs"
 	"engo.io/engo"
 	"engo.io/engo/common"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/engoengine/math"
+	"github.com/engoengine/math/imath"
 	"github.com/kyhavlov/go-dnd/mapgen"
 	"github.com/kyhavlov/go-dnd/structs"
+	"image/color"
+	"math/rand"
 	"sort"
 )
 
@@ -23,6 +27,7 @@ type Event interface {
 // it's probably worth trying to keep the number of different events low for simplicity
 func RegisterEvents() {
 	gob.Register(&GameStart{})
+	gob.Register(&ResumeGame{})
 	gob.Register(&SetPlayerID{})
 	gob.Register(&NewPlayer{})
 	gob.Register(&PlayerAction{})
@@ -32,80 +37,138 @@ func RegisterEvents() {
 	gob.Register(&Move{})
 	gob.Register(&UseSkill{})
 	gob.Register(&PickupItem{})
+	gob.Register(&DropItem{})
+	gob.Register(&SpendStatPoint{})
+	gob.Register(&PassItem{})
 	gob.Register(&EquipItem{})
 	gob.Register(&UnequipItem{})
 	gob.Register(&EnemyTurn{})
+	gob.Register(&ToggleDoor{})
+	gob.Register(&UseItem{})
 }
 
 // Starts the game, generating the map from the given seed
 type GameStart struct {
 	RandomSeed  int64
 	PlayerCount int
+
+	// Which map generator to use and how to set it up
+	Generator string
+	Params    mapgen.Params
+
+	// The contents of a map file to start on instead of generating the first floor
+	MapFile []byte
 }
 
 func (gs GameStart) Process(w *ecs.World, dt float32) bool {
 	log.Infof("Got random seed from server: %d", gs.RandomSeed)
-	level := mapgen.GenerateMap(gs.RandomSeed)
+	startLevel(w, gs, gs.firstFloor(), gs.PlayerCount)
+	return true
+}
+
+// Makes the first floor of the dungeon, from the map file if there is one
+func (gs GameStart) firstFloor() *mapgen.Map {
+	var level *mapgen.Map
+	if len(gs.MapFile) > 0 {
+		var err error
+		level, err = mapgen.ParseMapFile(gs.MapFile)
+		if err != nil {
+			log.Errorf("Error loading map file, generating a map instead: %s", err)
+		} else if level.Seed == 0 {
+			level.Seed = gs.RandomSeed
+		}
+	}
+	if level == nil {
+		level = mapgen.Generate(gs.Generator, gs.Params, gs.RandomSeed, 1)
+	}
+	return level
+}
+
+// Sets up the UI and turns for the players, then loads the level the game starts on
+func startLevel(w *ecs.World, start GameStart, level *mapgen.Map, playerCount int) {
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *UiSystem:
-			sys.InitUI(w, gs.PlayerCount)
+			sys.InitUI(w, playerCount)
 		case *TurnSystem:
-			for i := 0; i < gs.PlayerCount; i++ {
+			for i := 0; i < playerCount; i++ {
 				sys.PlayerReady[PlayerID(i)] = false
 			}
 		case *MapSystem:
-			sys.MapInfo = level
-			sys.Tiles = make([][]*structs.Tile, level.Width)
-			for i, _ := range sys.Tiles {
-				sys.Tiles[i] = make([]*structs.Tile, level.Height)
-			}
-			sys.CreatureLocations = make([][]*structs.Creature, level.Width)
-			for i, _ := range sys.CreatureLocations {
-				sys.CreatureLocations[i] = make([]*structs.Creature, level.Height)
-			}
-			sys.ItemLocations = make([][][]*structs.Item, level.Width)
-			for i, _ := range sys.ItemLocations {
-				sys.ItemLocations[i] = make([][]*structs.Item, level.Height)
-				for j, _ := range sys.ItemLocations[i] {
-					sys.ItemLocations[i][j] = make([]*structs.Item, 0)
-				}
-			}
+			sys.Start = start
 		}
 	}
 
-	for _, tile := range level.Tiles {
-		AddTile(w, tile)
-	}
-	for _, creature := range level.Creatures {
-		AddCreature(w, creature)
+	LoadLevel(w, level)
+}
+
+// Tears down the current floor and generates the next one down, moving the players
+// (and everything they're carrying) to the start of the new floor
+type Descend struct {
+	Floor int
+}
+
+func (d *Descend) Process(w *ecs.World, dt float32) bool {
+	var mapSystem *MapSystem
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			mapSystem = sys
+		}
 	}
 
-	// Make some test items
-	staff := structs.NewItem("Sapphire Staff", structs.GridPoint{
-		X: level.StartLoc.X + 1,
-		Y: level.StartLoc.Y + 2,
-	})
-	staff.OnGround = true
-	AddItem(w, staff)
+	log.Infof("Descending to floor %d", d.Floor)
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *InputSystem:
+			sys.SaveCharacter()
+		}
+	}
+	mapSystem.ClearLevel()
+	info := mapSystem.MapInfo
+	level := mapgen.Generate(info.Generator, info.Params, info.Seed, d.Floor)
+	LoadLevel(w, level)
 
-	armor := structs.NewItem("Leather Armor", structs.GridPoint{
-		X: level.StartLoc.X + 1,
-		Y: level.StartLoc.Y + 2,
-	})
-	armor.OnGround = true
-	AddItem(w, armor)
+	for _, id := range sortedPlayerIDs(mapSystem) {
+		player := mapSystem.Players[id]
+		if player.Dead {
+			continue
+		}
+		loc := PlayerSpawnLocation(level, id)
+		player.Position = loc.ToPixels()
+		mapSystem.CreatureLocations[loc.X][loc.Y] = player
+	}
 
-	spear := structs.NewItem("Ice Spear", structs.GridPoint{
-		X: level.StartLoc.X + 2,
-		Y: level.StartLoc.Y + 2,
-	})
-	spear.OnGround = true
-	AddItem(w, spear)
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *InputSystem:
+			if sys.player != nil {
+				engo.Mailbox.Dispatch(common.CameraMessage{Axis: common.XAxis, Value: sys.player.Position.X, Incremental: false})
+				engo.Mailbox.Dispatch(common.CameraMessage{Axis: common.YAxis, Value: sys.player.Position.Y, Incremental: false})
+			}
+		}
+	}
 
 	return true
 }
 
+// Returns where the player with the given ID starts on a level
+func PlayerSpawnLocation(level *mapgen.Map, id PlayerID) structs.GridPoint {
+	if int(id) >= len(level.SpawnPoints) {
+		log.Errorf("No spawn point for player %d, only have %d", id, len(level.SpawnPoints))
+		return level.StartLoc
+	}
+	return level.SpawnPoints[id]
+}
+
+// Tints for telling the players apart, since players of the same class share a sprite
+var playerTints = []color.Color{
+	color.White,
+	color.RGBA{170, 200, 255, 255},
+	color.RGBA{180, 255, 170, 255},
+	color.RGBA{255, 190, 160, 255},
+}
+
 // Sets the PlayerID of the local InputSystem, so we know which player we are and what we control
 type SetPlayerID struct {
 	PlayerID
@@ -123,30 +186,59 @@ func (event *SetPlayerID) Process(w *ecs.World, dt float32) bool {
 	return true
 }
 
-// Spawns a player with the given ID at the given GridPoint
+// Spawns a player with the given ID at the given GridPoint, either as their saved character or
+// as a new character of the given class. Players returning to a resumed game are put back
+// where they were instead.
 type NewPlayer struct {
 	PlayerID
-	Life int
+	Life  int
+	Class string
+
+	Name      string
+	Character *structs.CharacterSave
+	Resumed   *structs.SavedCreature
 }
 
 func (event *NewPlayer) Process(w *ecs.World, dt float32) bool {
-	sheet := common.NewSpritesheetFromFile(structs.SpritesheetPath, structs.TileWidth, structs.TileWidth)
-
-	var spawnLoc structs.GridPoint
+	var mapSystem *MapSystem
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
-			spawnLoc = sys.MapInfo.StartLoc
+			mapSystem = sys
+		}
+	}
+	spawnLoc := PlayerSpawnLocation(mapSystem.MapInfo, event.PlayerID)
+
+	var player *structs.Creature
+	if event.Resumed != nil {
+		var err error
+		if player, err = event.Resumed.Creature(); err != nil {
+			log.Errorf("Error resuming character, starting a new one: %s", err)
+		} else {
+			spawnLoc = event.Resumed.Position
+		}
+	}
+
+	// In a resumed game, someone else may already be standing there
+	if free := mapSystem.nearestFreeTile(spawnLoc); free != spawnLoc {
+		log.Infof("Spawn point %v for player %d is taken, using %v", spawnLoc, event.PlayerID, free)
+		spawnLoc = free
+		if player != nil {
+			player.Position = spawnLoc.ToPixels()
 		}
 	}
-	spawnLoc.X += int(event.PlayerID)
-	spawnLoc.Y += 3
 
-	player := structs.NewCreature("Player", spawnLoc)
-	player.IsPlayerTeam = true
-	player.RenderComponent = common.RenderComponent{
-		Drawable: sheet.Cell(player.Icon + int(event.PlayerID)),
+	if player == nil && event.Character != nil {
+		var err error
+		if player, err = event.Character.Character(spawnLoc); err != nil {
+			log.Errorf("Error loading character, starting a new one: %s", err)
+		}
 	}
+	if player == nil {
+		player = structs.NewPlayer(event.Class, spawnLoc)
+		player.Character = event.Name
+	}
+	player.Color = playerTints[int(event.PlayerID)%len(playerTints)]
 	AddCreature(w, player)
 
 	isLocalPlayer := false
@@ -159,11 +251,6 @@ func (event *NewPlayer) Process(w *ecs.World, dt float32) bool {
 				sys.player = player
 				isLocalPlayer = true
 			}
-		case *LightSystem:
-			sys.Add(&player.BasicEntity, &DynamicLightSource{
-				spaceComponent: &player.SpaceComponent,
-				Brightness:     250,
-			})
 		case *TurnSystem:
 			sys.PlayerReady[event.PlayerID] = false
 		case *UiSystem:
@@ -180,7 +267,7 @@ func (event *NewPlayer) Process(w *ecs.World, dt float32) bool {
 		engo.Mailbox.Dispatch(common.CameraMessage{Axis: common.YAxis, Value: player.SpaceComponent.Position.Y, Incremental: false})
 	}
 
-	log.Infof("New player added at %v, ID: %d", spawnLoc, event.PlayerID)
+	log.Infof("New %s added at %v, ID: %d", player.Class, spawnLoc, event.PlayerID)
 
 	return true
 }
@@ -226,14 +313,22 @@ func (p *PlayerAction) Process(w *ecs.World, dt float32) bool {
 					ui.AddActionIndicator(p.Action, p.PlayerID, mapSystem, nil)
 				}
 			default:
+				// The action happens after the move if there's one locked in first
 				effectiveSourceLoc := structs.PointToGridPoint(mapSystem.Players[p.PlayerID].Position)
+				if sys.PlayerMovingFirst(p.PlayerID) {
+					path := sys.PlayerActions[p.PlayerID][0].(*Move).Path
+					effectiveSourceLoc = path[len(path)-1]
+				}
+				if err := sys.ValidAction(mapSystem, p.Action, effectiveSourceLoc); err != nil {
+					reportActionError(w, mapSystem.Players[p.PlayerID].NetworkID, err)
+					return true
+				}
+
 				if (sys.PlayerHasMove(p.PlayerID) && len(sys.PlayerActions[p.PlayerID]) == 1) || len(sys.PlayerActions[p.PlayerID]) == 2 {
 					ui.ResetActionIndicators(p.PlayerID)
 					switch sys.PlayerActions[p.PlayerID][0].(type) {
 					case *Move:
 						sys.PlayerActions[p.PlayerID][1] = p.Action
-						path := sys.PlayerActions[p.PlayerID][0].(*Move).Path
-						effectiveSourceLoc = path[len(path)-1]
 					default:
 						sys.PlayerActions[p.PlayerID][0] = p.Action
 					}
@@ -241,10 +336,6 @@ func (p *PlayerAction) Process(w *ecs.World, dt float32) bool {
 						ui.AddActionIndicator(action, p.PlayerID, mapSystem, &effectiveSourceLoc)
 					}
 				} else {
-					if !sys.PlayerHasMove(p.PlayerID) {
-						path := sys.PlayerActions[p.PlayerID][0].(*Move).Path
-						effectiveSourceLoc = path[len(path)-1]
-					}
 					sys.PlayerActions[p.PlayerID] = append(sys.PlayerActions[p.PlayerID], p.Action)
 					ui.AddActionIndicator(p.Action, p.PlayerID, mapSystem, &effectiveSourceLoc)
 				}
@@ -301,12 +392,20 @@ func (t *TurnChange) Process(w *ecs.World, dt float32) bool {
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
+			sys.TriggerTurnEndHazards(!t.PlayersTurn)
+			if t.PlayersTurn {
+				for _, id := range sortedPlayerIDs(sys) {
+					if player := sys.Players[id]; !player.Dead {
+						sys.DetectHazards(player)
+					}
+				}
+			}
 			for _, creature := range sys.Creatures {
 				if creature.IsPlayerTeam == t.PlayersTurn {
-					creature.Stamina += creature.StaminaRegen
-					if creature.Stamina > creature.MaxStamina {
-						creature.Stamina = creature.MaxStamina
-					}
+					creature.TickStatuses()
+					stats := creature.Stats()
+					creature.Life = imath.Min(creature.Life, stats.MaxLife)
+					creature.Stamina = imath.Min(creature.Stamina+stats.StaminaRegen, stats.MaxStamina)
 				}
 			}
 		}
@@ -314,6 +413,8 @@ func (t *TurnChange) Process(w *ecs.World, dt float32) bool {
 
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
+		case *LightSystem:
+			sys.ClearTemporaryLights()
 		case *TurnSystem:
 			if t.PlayersTurn {
 				log.Info("Beginning player turn")
@@ -331,6 +432,19 @@ type NamedEvent interface {
 	Name() string
 }
 
+// Logs an action that couldn't be carried out, and tells the player about it if it was theirs
+func reportActionError(w *ecs.World, creatureID structs.NetworkID, err error) {
+	log.Warnf("Creature id %d couldn't perform action: %s", creatureID, err)
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *UiSystem:
+			if sys.input.player != nil && sys.input.player.NetworkID == creatureID {
+				sys.ShowMessage(err.Error())
+			}
+		}
+	}
+}
+
 // Moves the entity with the given Id along the path
 type Move struct {
 	Id   structs.NetworkID
@@ -357,6 +471,16 @@ func (move *Move) Process(w *ecs.World, dt float32) bool {
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
+			// Stop short of anything that's stopped being walkable since the move was planned,
+			// like a door being shut
+			for i := 1; i < len(move.Path); i++ {
+				if !isWalkable(sys.GetTileAt(move.Path[i])) {
+					move.Path = move.Path[:i]
+					break
+				}
+			}
+			move.Path = stopAtHazards(move.Path, sys)
+
 			// Check if the path needs to be ended early because of an occupying creature
 			last := 0
 			for i := len(move.Path) - 1; i > 0; i-- {
@@ -383,6 +507,16 @@ func (move *Move) Process(w *ecs.World, dt float32) bool {
 					if ok {
 						sys.CreatureLocations[move.Path[0].X][move.Path[0].Y] = nil
 						sys.CreatureLocations[move.Path[move.current-1].X][move.Path[move.current-1].Y] = creature
+
+						// Set off any hazards that were stepped on along the way
+						for _, point := range move.Path[1:] {
+							if !sys.TriggerHazard(creature, sys.GetTileAt(point), false) {
+								break
+							}
+						}
+						if creature.IsPlayerTeam && !creature.Dead {
+							sys.DetectHazards(creature)
+						}
 					}
 
 					return true
@@ -432,37 +566,36 @@ type PickupItem struct {
 
 func (p *PickupItem) Name() string { return "Picking up item: " + p.ItemName }
 func (p *PickupItem) Process(w *ecs.World, dt float32) bool {
+	// Keep hold of the item, since it's taken out of the map system if it goes on a stack
+	var item *structs.Item
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
-			item := sys.Items[p.ItemId]
-			// If the item is already picked up, exit early
-			if !item.OnGround {
+			// If the item is already picked up (or was added to someone's stack), exit early
+			var ok bool
+			item, ok = sys.Items[p.ItemId]
+			if !ok || !item.OnGround {
 				return true
 			}
-			creature := sys.Creatures[p.CreatureId]
-			// put the item in the first empty inventory slot
-			for i, slot := range creature.Inventory {
-				if slot == nil {
-					creature.Inventory[i] = item
-					break
-				}
-			}
-			log.Infof("Inventory: %v", creature.Inventory)
-			itemLoc := structs.PointToGridPoint(item.Position)
-			itemPile := sys.ItemLocations[itemLoc.X][itemLoc.Y]
-			for i, _ := range itemPile {
-				if itemPile[i].NetworkID == p.ItemId {
-					if len(itemPile) == 1 {
-						sys.ItemLocations[itemLoc.X][itemLoc.Y] = []*structs.Item{}
-					} else {
-						itemPile[i] = itemPile[len(itemPile)-1]
-						sys.ItemLocations[itemLoc.X][itemLoc.Y] = itemPile[:len(itemPile)-1]
-					}
-				}
+			// put the item on a stack or in the first empty inventory slot, leaving it on the
+			// ground if there's no room
+			if err := p.validate(sys, nil); err != nil {
+				reportActionError(w, p.CreatureId, err)
+				return true
 			}
+			creature := sys.Creatures[p.CreatureId]
+			sys.removeFromPile(item)
 			item.RenderComponent.Hidden = true
 			item.OnGround = false
+			sys.StoreItem(creature, item)
+			log.Infof("Inventory: %v", creature.Inventory)
+		}
+	}
+
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *LightSystem:
+			sys.Remove(item.BasicEntity)
 		}
 	}
 
@@ -478,6 +611,180 @@ func (p *PickupItem) Process(w *ecs.World, dt float32) bool {
 	return true
 }
 
+func (p *PickupItem) validate(sys *MapSystem, sourceLoc *structs.GridPoint) error {
+	if !CanPickupItem(sys, p.CreatureId, p.ItemId, sourceLoc) {
+		return fmt.Errorf("Can't pick up %s, it needs to be where you're standing with room for it in your bags", p.ItemName)
+	}
+	return nil
+}
+
+// Drops the item in the creature's inventory slot on the tile it's standing on
+type DropItem struct {
+	InventorySlot int
+	CreatureId    structs.NetworkID
+	ItemName      string
+}
+
+func (d *DropItem) Name() string { return "Dropping item: " + d.ItemName }
+func (d *DropItem) Process(w *ecs.World, dt float32) bool {
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			if err := d.validate(sys); err != nil {
+				reportActionError(w, d.CreatureId, err)
+				return true
+			}
+			creature := sys.Creatures[d.CreatureId]
+			sys.DropItem(creature, false, d.InventorySlot, structs.PointToGridPoint(creature.Position))
+		}
+	}
+
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *UiSystem:
+			if d.CreatureId == sys.input.player.NetworkID {
+				sys.UpdatePlayerDisplay()
+			}
+		}
+	}
+	return true
+}
+
+func (d *DropItem) validate(sys *MapSystem) error {
+	if !CanDropItem(sys, d.CreatureId, d.InventorySlot) {
+		return fmt.Errorf("There's no item in inventory slot %d to drop", d.InventorySlot+1)
+	}
+	return nil
+}
+
+// Hands the item in the creature's inventory slot to an ally standing next to it
+type PassItem struct {
+	InventorySlot int
+	CreatureId    structs.NetworkID
+	TargetId      structs.NetworkID
+	ItemName      string
+}
+
+func (p *PassItem) Name() string { return "Passing item: " + p.ItemName }
+func (p *PassItem) Process(w *ecs.World, dt float32) bool {
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			if err := p.validate(sys, nil); err != nil {
+				reportActionError(w, p.CreatureId, err)
+				return true
+			}
+			creature := sys.Creatures[p.CreatureId]
+			item := creature.Inventory[p.InventorySlot]
+			creature.Inventory[p.InventorySlot] = nil
+			sys.StoreItem(sys.Creatures[p.TargetId], item)
+		}
+	}
+
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *UiSystem:
+			if p.CreatureId == sys.input.player.NetworkID || p.TargetId == sys.input.player.NetworkID {
+				sys.UpdatePlayerDisplay()
+			}
+		}
+	}
+	return true
+}
+
+func (p *PassItem) validate(sys *MapSystem, sourceLoc *structs.GridPoint) error {
+	if !CanPassItem(sys, p.CreatureId, p.TargetId, p.InventorySlot, sourceLoc) {
+		return fmt.Errorf("Can't pass %s, allies need to be next to you with room to take it", p.ItemName)
+	}
+	return nil
+}
+
+// CanPickupItem returns whether the creature can pick up the item, which has to be on the
+// ground where the creature is with room for it in the creature's inventory. sourceLoc
+// overrides where the creature is, if set.
+func CanPickupItem(sys *MapSystem, creatureID, itemID structs.NetworkID, sourceLoc *structs.GridPoint) bool {
+	creature, ok := sys.Creatures[creatureID]
+	if !ok || creature.Dead {
+		return false
+	}
+	item, ok := sys.Items[itemID]
+	if !ok || !item.OnGround || !creature.HasRoomFor(item) {
+		return false
+	}
+
+	source := structs.PointToGridPoint(creature.Position)
+	if sourceLoc != nil {
+		source = *sourceLoc
+	}
+	return structs.PointToGridPoint(item.Position) == source
+}
+
+// CanDropItem returns whether the creature has an item in the given inventory slot
+func CanDropItem(sys *MapSystem, creatureID structs.NetworkID, slot int) bool {
+	creature, ok := sys.Creatures[creatureID]
+	return ok && !creature.Dead && creature.InventoryItem(slot) != nil
+}
+
+// CanPassItem returns whether the creature can hand the item in its inventory slot to the
+// target, which has to be a living ally next to it with room in its inventory. sourceLoc
+// overrides where the creature is, if set.
+func CanPassItem(sys *MapSystem, creatureID, targetID structs.NetworkID, slot int, sourceLoc *structs.GridPoint) bool {
+	if creatureID == targetID || !CanDropItem(sys, creatureID, slot) {
+		return false
+	}
+	creature := sys.Creatures[creatureID]
+	target, ok := sys.Creatures[targetID]
+	if !ok || target.Dead || target.IsPlayerTeam != creature.IsPlayerTeam || !target.HasRoomFor(creature.Inventory[slot]) {
+		return false
+	}
+
+	source := structs.PointToGridPoint(creature.Position)
+	if sourceLoc != nil {
+		source = *sourceLoc
+	}
+	return source.DistanceTo(structs.PointToGridPoint(target.Position)) == 1
+}
+
+// Opens or closes the door next to the creature
+type ToggleDoor struct {
+	CreatureId structs.NetworkID
+	Location   structs.GridPoint
+}
+
+func (t *ToggleDoor) Name() string { return "Opening/closing door" }
+func (t *ToggleDoor) Process(w *ecs.World, dt float32) bool {
+	var door *structs.Tile
+	var random *rand.Rand
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			creature, ok := sys.Creatures[t.CreatureId]
+			if !ok || !sys.InBounds(t.Location) {
+				return true
+			}
+			if !CanToggleDoor(structs.PointToGridPoint(creature.Position), t.Location, sys) {
+				log.Infof("Creature id %d can't open or close the door at %v", t.CreatureId, t.Location)
+				return true
+			}
+			door = sys.GetTileAt(t.Location)
+			random = sys.Random
+		}
+	}
+
+	ReplaceTile(w, structs.NewTile(door.Toggle, t.Location, random))
+	return true
+}
+
+// CanToggleDoor returns whether a creature standing at the source can open or close the door at
+// the given location. Doors have to be next to the creature, and can't be shut on anything.
+func CanToggleDoor(source, loc structs.GridPoint, sys *MapSystem) bool {
+	door := sys.GetTileAt(loc)
+	if door == nil || door.Toggle == "" || source.DistanceTo(loc) != 1 {
+		return false
+	}
+	return sys.GetCreatureAt(loc) == nil && len(sys.GetItemsAt(loc)) == 0
+}
+
 type EquipItem struct {
 	InventorySlot int
 	CreatureId    structs.NetworkID
@@ -486,18 +793,30 @@ type EquipItem struct {
 
 func (e *EquipItem) Name() string { return "Equipping item: " + e.ItemName }
 func (e *EquipItem) Process(w *ecs.World, dt float32) bool {
+	var creature *structs.Creature
+	var item, equipped *structs.Item
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
-			creature := sys.Creatures[e.CreatureId]
-			item := creature.Inventory[e.InventorySlot]
-			equipped := creature.Equipment[item.Type]
-			creature.Equipment[item.Type] = item
-			creature.Inventory[e.InventorySlot] = equipped
+			if err := e.validate(sys); err != nil {
+				reportActionError(w, e.CreatureId, err)
+				return true
+			}
+			creature = sys.Creatures[e.CreatureId]
+			item = creature.InventoryItem(e.InventorySlot)
+			equipped = creature.Equipment[item.Type]
+			creature.EquipFromInventory(e.InventorySlot)
+		}
+	}
 
-			// Adjust max life/stamina based on bonuses
-			creature.Life += item.Bonuses.MaxLife
-			creature.Stamina += item.Bonuses.MaxStamina
+	// Equipped items give off light around the creature holding them
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *LightSystem:
+			if equipped != nil {
+				sys.Remove(equipped.BasicEntity)
+			}
+			sys.AddLight(&item.BasicEntity, item.Light, &creature.SpaceComponent)
 		}
 	}
 
@@ -513,6 +832,18 @@ func (e *EquipItem) Process(w *ecs.World, dt float32) bool {
 	return true
 }
 
+func (e *EquipItem) validate(sys *MapSystem) error {
+	creature, ok := sys.Creatures[e.CreatureId]
+	if !ok {
+		return fmt.Errorf("Only the living can equip items")
+	}
+	item := creature.InventoryItem(e.InventorySlot)
+	if item == nil {
+		return fmt.Errorf("There's no item in inventory slot %d to equip", e.InventorySlot+1)
+	}
+	return creature.EquipError(item)
+}
+
 type UnequipItem struct {
 	EquipSlot  int
 	CreatureId structs.NetworkID
@@ -521,23 +852,24 @@ type UnequipItem struct {
 
 func (e *UnequipItem) Name() string { return "Unequip item: " + e.ItemName }
 func (e *UnequipItem) Process(w *ecs.World, dt float32) bool {
+	var item *structs.Item
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
-			creature := sys.Creatures[e.CreatureId]
-			item := creature.Equipment[e.EquipSlot]
-			// put the item in the first empty inventory slot
-			for i, slot := range creature.Inventory {
-				if slot == nil {
-					creature.Inventory[i] = item
-					break
-				}
+			if err := e.validate(sys); err != nil {
+				reportActionError(w, e.CreatureId, err)
+				return true
 			}
-			creature.Equipment[e.EquipSlot] = nil
+			creature := sys.Creatures[e.CreatureId]
+			item = creature.Equipment[e.EquipSlot]
+			creature.UnequipToInventory(e.EquipSlot)
+		}
+	}
 
-			// Adjust max life/stamina based on bonuses
-			creature.Life -= item.Bonuses.MaxLife
-			creature.Stamina -= item.Bonuses.MaxStamina
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *LightSystem:
+			sys.Remove(item.BasicEntity)
 		}
 	}
 
@@ -553,14 +885,65 @@ func (e *UnequipItem) Process(w *ecs.World, dt float32) bool {
 	return true
 }
 
+func (e *UnequipItem) validate(sys *MapSystem) error {
+	creature, ok := sys.Creatures[e.CreatureId]
+	if !ok {
+		return fmt.Errorf("Only the living can unequip items")
+	}
+	return creature.UnequipError(e.EquipSlot)
+}
+
+// Uses up a charge of the consumable in the creature's inventory slot, aimed at the target if
+// it needs one
+type UseItem struct {
+	InventorySlot int
+	CreatureId    structs.NetworkID
+	ItemName      string
+	Target        structs.SkillTarget
+}
+
+func (e *UseItem) Name() string { return "Using item: " + e.ItemName }
+func (e *UseItem) Process(w *ecs.World, dt float32) bool {
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			if !CanUseItem(sys, e.CreatureId, e.InventorySlot, e.Target, nil) {
+				reportActionError(w, e.CreatureId, fmt.Errorf("Can't use %s there", e.ItemName))
+				return true
+			}
+			useItem(sys, sys.Creatures[e.CreatureId], e.InventorySlot, e.Target)
+		}
+	}
+
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *UiSystem:
+			if e.CreatureId == sys.input.player.NetworkID {
+				sys.UpdatePlayerDisplay()
+			}
+		}
+	}
+	return true
+}
+
 type EnemyTurnStart struct{}
 
-// Decide the turn order of the enemies based on sorted NetworkIDs
+// Decide the turn order of the enemies based on sorted NetworkIDs. If all the players
+// are standing on the stairs, head down to the next floor instead.
 func (e *EnemyTurnStart) Process(w *ecs.World, dt float32) bool {
 	var creatures []int
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
+			if sys.PlayersOnStairs() {
+				for _, system := range w.Systems() {
+					switch turn := system.(type) {
+					case *TurnSystem:
+						turn.event.AddEvents(&Descend{sys.MapInfo.Floor + 1}, &TurnChange{true})
+					}
+				}
+				return true
+			}
 			for _, creature := range sys.Creatures {
 				if !creature.IsPlayerTeam {
 					creatures = append(creatures, int(creature.NetworkID))
@@ -575,10 +958,15 @@ func (e *EnemyTurnStart) Process(w *ecs.World, dt float32) bool {
 		case *TurnSystem:
 			sys.enemyTurnOrder = creatures
 			if len(creatures) > 0 {
-				sys.event.AddEvents(&EnemyTurn{creatures[0]})
+				sys.event.AddEvents(&EnemyTurn{0})
 			} else {
 				sys.event.AddEvents(&TurnChange{true})
 			}
+		case *MapSystem:
+			// There's no one left to hear this round's noises
+			if len(creatures) == 0 {
+				sys.Noises = nil
+			}
 		}
 	}
 	return true
@@ -595,6 +983,10 @@ func (e *EnemyTurn) Process(w *ecs.World, dt float32) bool {
 		switch sys := system.(type) {
 		case *EventSystem:
 			if sys.serverRoom == nil {
+				// Only the server plans, but the noises still need clearing along with it
+				if e.Index == 0 {
+					clearNoises(w)
+				}
 				return true
 			}
 		}
@@ -612,7 +1004,12 @@ func (e *EnemyTurn) Process(w *ecs.World, dt float32) bool {
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *MapSystem:
-			actions := ProcessCreatureTurn(structs.NetworkID(turnOrder[e.Index]), sys)
+			// Plan out the whole enemy turn before the first enemy acts
+			if e.Index == 0 {
+				turn.enemyPlan = NewGroupPlan(turnOrder, sys)
+				sys.Noises = nil
+			}
+			actions := ProcessCreatureTurn(structs.NetworkID(turnOrder[e.Index]), sys, turn.enemyPlan)
 			if e.Index < len(turnOrder)-1 {
 				actions = append(actions, &EnemyTurn{e.Index + 1})
 			} else {
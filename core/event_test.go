@@ -0,0 +1,226 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestDescendClearsLevel(t *testing.T) {
+	w, ms := newTestWorld(
+		"######",
+		"#....#",
+		"######",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 3, Y: 1})
+	skeleton.StartingItems = []string{"Torch"}
+	AddCreature(w, skeleton)
+	potion := structs.NewItem("Leather Armor", structs.GridPoint{X: 4, Y: 1})
+	AddItem(w, potion)
+
+	descend := &Descend{Floor: 2}
+	if !descend.Process(w, 0) {
+		t.Fatal("bad: descend didn't finish")
+	}
+
+	if ms.Creatures[player.NetworkID] != player || ms.SpaceComponents[player.NetworkID] == nil {
+		t.Fatal("bad: player was cleared")
+	}
+	if _, ok := ms.SpaceComponents[skeleton.NetworkID]; ok {
+		t.Fatal("bad: skeleton is still in the space components")
+	}
+	if _, ok := ms.SpaceComponents[potion.NetworkID]; ok {
+		t.Fatal("bad: potion is still in the space components")
+	}
+
+	// Everything left should be something on the new floor or carried by the players
+	for entity, id := range ms.networkIds {
+		if ms.Creatures[id] == nil && ms.Items[id] == nil {
+			t.Errorf("bad: entity %d with network id %d isn't on the floor", entity.ID(), id)
+		}
+	}
+	if len(ms.SpaceComponents) != len(ms.networkIds) {
+		t.Errorf("bad: %d space components, %d network ids", len(ms.SpaceComponents), len(ms.networkIds))
+	}
+}
+
+func TestPassItem(t *testing.T) {
+	w, ms := newTestWorld(
+		"######",
+		"#....#",
+		"#....#",
+		"######",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+	ally := addTestPlayer(w, ms, 1, "Warrior", structs.GridPoint{X: 2, Y: 1})
+	farAlly := addTestPlayer(w, ms, 2, "Warrior", structs.GridPoint{X: 4, Y: 2})
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 1, Y: 2})
+	AddCreature(w, skeleton)
+	torch := structs.NewItem("Torch", structs.GridPoint{X: 1, Y: 1})
+	player.SetInventoryItem(0, torch)
+
+	// Items can only go to a living ally next to the player, from a slot with something in it
+	turns := &TurnSystem{}
+	here := structs.GridPoint{X: 1, Y: 1}
+	for i, action := range []*PassItem{
+		{InventorySlot: 0, CreatureId: player.NetworkID, TargetId: player.NetworkID},
+		{InventorySlot: 0, CreatureId: player.NetworkID, TargetId: skeleton.NetworkID},
+		{InventorySlot: 0, CreatureId: player.NetworkID, TargetId: farAlly.NetworkID},
+		{InventorySlot: 1, CreatureId: player.NetworkID, TargetId: ally.NetworkID},
+	} {
+		if turns.ValidAction(ms, action, here) == nil {
+			t.Errorf("bad: case %d: could pass to %d", i, action.TargetId)
+		}
+	}
+
+	// Moving first is taken into account when the pass is locked in
+	toFarAlly := &PassItem{InventorySlot: 0, CreatureId: player.NetworkID, TargetId: farAlly.NetworkID}
+	if err := turns.ValidAction(ms, toFarAlly, structs.GridPoint{X: 4, Y: 1}); err != nil {
+		t.Fatalf("bad: couldn't pass after moving next to the ally: %s", err)
+	}
+
+	// An ally with a full inventory can't take anything
+	for i := 0; i < ally.InventoryCapacity(); i++ {
+		ally.SetInventoryItem(i, structs.NewItem("Torch", structs.GridPoint{}))
+	}
+	toAlly := &PassItem{InventorySlot: 0, CreatureId: player.NetworkID, TargetId: ally.NetworkID}
+	if turns.ValidAction(ms, toAlly, here) == nil {
+		t.Fatal("bad: passed to a full inventory")
+	}
+	ally.Inventory[3] = nil
+
+	toAlly.Process(w, 0)
+	if player.Inventory[0] != nil || ally.Inventory[3] != torch {
+		t.Fatalf("bad: %v %v", player.Inventory, ally.Inventory)
+	}
+}
+
+func TestDropItem(t *testing.T) {
+	w, ms := newTestWorld(
+		"####",
+		"#..#",
+		"####",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 2, Y: 1})
+	// The first torch gets equipped, so the second goes in the inventory
+	GiveItem(w, player, structs.NewItem("Torch", structs.GridPoint{X: 2, Y: 1}))
+	torch := structs.NewItem("Torch", structs.GridPoint{X: 2, Y: 1})
+	GiveItem(w, player, torch)
+	if player.Inventory[0] != torch {
+		t.Fatalf("bad: %v", player.Inventory)
+	}
+
+	if CanDropItem(ms, player.NetworkID, 1) {
+		t.Fatal("bad: could drop from an empty slot")
+	}
+
+	(&DropItem{InventorySlot: 0, CreatureId: player.NetworkID}).Process(w, 0)
+	pile := ms.GetItemsAt(structs.GridPoint{X: 2, Y: 1})
+	if player.Inventory[0] != nil || len(pile) != 1 || pile[0] != torch || !torch.OnGround {
+		t.Fatalf("bad: inventory %v, pile %v", player.Inventory, pile)
+	}
+	if structs.PointToGridPoint(torch.Position) != (structs.GridPoint{X: 2, Y: 1}) || ms.Items[torch.NetworkID] != torch {
+		t.Fatalf("bad: torch at %v", torch.Position)
+	}
+}
+
+func TestPickupStackingItem(t *testing.T) {
+	w, ms := newTestWorld(
+		"######",
+		"#....#",
+		"######",
+	)
+	loc := structs.GridPoint{X: 1, Y: 1}
+	player := addTestPlayer(w, ms, 0, "Warrior", loc)
+	GiveItem(w, player, structs.NewItem("Healing Potion", loc))
+	slot := player.StackSlot(structs.NewItem("Healing Potion", loc))
+	if slot == -1 {
+		t.Fatalf("bad: no potion to stack onto in %v", player.Inventory)
+	}
+
+	potion := structs.NewItem("Healing Potion", loc)
+	AddItem(w, potion)
+	pickup := &PickupItem{ItemId: potion.NetworkID, CreatureId: player.NetworkID, ItemName: potion.Name}
+	if err := (&TurnSystem{}).ValidAction(ms, pickup, loc); err != nil {
+		t.Fatal(err)
+	}
+	if !pickup.Process(w, 0) {
+		t.Fatal("bad: pickup didn't finish")
+	}
+
+	if player.Inventory[slot].Count != 2 {
+		t.Fatalf("bad: %d potions", player.Inventory[slot].Count)
+	}
+	if _, ok := ms.Items[potion.NetworkID]; ok || len(ms.GetItemsAt(loc)) != 0 {
+		t.Fatal("bad: the stacked potion is still on the map")
+	}
+}
+
+func TestPickupSameItemTwice(t *testing.T) {
+	w, ms := newTestWorld(
+		"######",
+		"#....#",
+		"######",
+	)
+	loc := structs.GridPoint{X: 1, Y: 1}
+	player := addTestPlayer(w, ms, 0, "Warrior", loc)
+	ally := addTestPlayer(w, ms, 1, "Warrior", structs.GridPoint{X: 2, Y: 1})
+	GiveItem(w, player, structs.NewItem("Healing Potion", loc))
+	GiveItem(w, ally, structs.NewItem("Healing Potion", loc))
+	potion := structs.NewItem("Healing Potion", loc)
+	AddItem(w, potion)
+
+	// Pickups can only be locked in for items where the player will be standing
+	turns := &TurnSystem{}
+	if turns.ValidAction(ms, &PickupItem{ItemId: potion.NetworkID, CreatureId: ally.NetworkID}, structs.GridPoint{X: 2, Y: 1}) == nil {
+		t.Fatal("bad: picked up an item from the next tile over")
+	}
+
+	// Both players going for the same potion in one turn: the first one stacks it, and the
+	// second finds it gone instead of picking it up again
+	first := &PickupItem{ItemId: potion.NetworkID, CreatureId: player.NetworkID, ItemName: potion.Name}
+	second := &PickupItem{ItemId: potion.NetworkID, CreatureId: ally.NetworkID, ItemName: potion.Name}
+	for _, pickup := range []*PickupItem{first, second, first} {
+		if !pickup.Process(w, 0) {
+			t.Fatal("bad: pickup didn't finish")
+		}
+	}
+
+	stack := player.Inventory[player.StackSlot(structs.NewItem("Healing Potion", loc))]
+	allyStack := ally.Inventory[ally.StackSlot(structs.NewItem("Healing Potion", loc))]
+	if stack.Count != 2 || allyStack.Count != 1 {
+		t.Fatalf("bad: %d and %d potions", stack.Count, allyStack.Count)
+	}
+
+	// A pickup from a creature that's gone does nothing
+	if !(&PickupItem{ItemId: potion.NetworkID, CreatureId: 999}).Process(w, 0) {
+		t.Fatal("bad: pickup didn't finish")
+	}
+}
+
+func TestNewPlayerSpawnTaken(t *testing.T) {
+	w, ms := newTestWorld(
+		"######",
+		"#....#",
+		"######",
+	)
+	ms.MapInfo.SpawnPoints = []structs.GridPoint{{X: 2, Y: 1}}
+	spawn := PlayerSpawnLocation(ms.MapInfo, 0)
+	skeleton := structs.NewCreature("Skeleton", spawn)
+	AddCreature(w, skeleton)
+
+	event := &NewPlayer{PlayerID: 0, Class: "Warrior"}
+	if !event.Process(w, 0) {
+		t.Fatal("bad: new player didn't finish")
+	}
+
+	player := ms.Players[0]
+	loc := structs.PointToGridPoint(player.Position)
+	if loc == spawn || loc.DistanceTo(spawn) != 1 {
+		t.Fatalf("bad: player spawned at %v, skeleton at %v", loc, spawn)
+	}
+	if ms.GetCreatureAt(spawn) != skeleton || ms.GetCreatureAt(loc) != player {
+		t.Fatal("bad: creature locations overwritten")
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/engoengine/math/imath"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// The extra cost the pathfinder gives known hazards, so creatures walk around them when they can
+const HazardPathPenalty = 10
+
+// Returns whether creatures on the team know about the hazard on the tile. Enemies know where
+// everything in their own dungeon is, but players only know about the hazards they've found.
+func knowsHazard(tile *structs.Tile, team Team) bool {
+	if tile == nil || !tile.Hazard.IsHazard() {
+		return false
+	}
+	return team == TeamEnemy || !tile.IsHidden()
+}
+
+// Returns the path cut short at the first hazard that stops movement
+func stopAtHazards(path []structs.GridPoint, sys *MapSystem) []structs.GridPoint {
+	for i := 1; i < len(path); i++ {
+		if tile := sys.GetTileAt(path[i]); tile != nil && tile.Hazard.StopsMovement {
+			return path[:i+1]
+		}
+	}
+	return path
+}
+
+// TriggerHazard applies the tile's hazard to a creature that stepped onto it, or that ended its
+// turn on it. Hidden hazards are revealed once they go off. Returns whether the creature survived.
+func (ms *MapSystem) TriggerHazard(creature *structs.Creature, tile *structs.Tile, turnEnd bool) bool {
+	if tile == nil || !tile.Hazard.IsHazard() {
+		return true
+	}
+
+	damage := tile.Hazard.StepDamage
+	if turnEnd {
+		damage = tile.Hazard.TurnEndDamage
+		creature.Stamina = imath.Max(creature.Stamina-tile.Hazard.StaminaDrain, 0)
+	}
+	if damage == 0 {
+		return true
+	}
+
+	if tile.IsHidden() {
+		log.Infof("Creature id %d set off a hidden %s", creature.NetworkID, tile.Name)
+		tile.Reveal()
+	}
+
+	creature.Life -= damage
+	log.Infof("Creature id %d took %d damage from %s, at %d life now", creature.NetworkID, damage, tile.Name, creature.Life)
+	ms.MakeNoise(tile.GridPoint, PainNoise)
+	if creature.Life <= 0 {
+		ms.RemoveCreature(creature)
+		return false
+	}
+	CheckBossPhases(creature, ms)
+	return true
+}
+
+// TriggerTurnEndHazards applies hazards to the creatures on the team whose turn just ended
+func (ms *MapSystem) TriggerTurnEndHazards(playerTeam bool) {
+	// Go in a fixed order, since creatures dying drops items with new network IDs
+	var ids []int
+	for id, creature := range ms.Creatures {
+		if creature.IsPlayerTeam == playerTeam {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		creature := ms.Creatures[structs.NetworkID(id)]
+		ms.TriggerHazard(creature, ms.GetTileAt(structs.PointToGridPoint(creature.Position)), true)
+	}
+}
+
+// DetectHazards reveals any hidden hazards the player is close enough to see
+func (ms *MapSystem) DetectHazards(player *structs.Creature) {
+	loc := structs.PointToGridPoint(player.Position)
+	for _, column := range ms.Tiles {
+		for _, tile := range column {
+			if tile != nil && tile.IsHidden() && loc.DistanceTo(tile.GridPoint) <= tile.Hazard.DetectRange && ms.HasLineOfSight(loc, tile.GridPoint) {
+				log.Infof("Player found a hidden %s at %v", tile.Name, tile.GridPoint)
+				tile.Reveal()
+			}
+		}
+	}
+}
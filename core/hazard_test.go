@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestHazardsTrigger(t *testing.T) {
+	w, ms := newTestWorld(
+		"#######",
+		"#.^..~#",
+		"#######",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 5, Y: 1})
+	AddCreature(w, skeleton)
+	trap := ms.GetTileAt(structs.GridPoint{X: 2, Y: 1})
+	if !trap.IsHidden() {
+		t.Fatal("bad: trap should start hidden")
+	}
+
+	// Stepping on the hidden trap stops the move there, hurts the player and reveals it
+	life := player.Life
+	move := &Move{Id: player.NetworkID, Path: []structs.GridPoint{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 3, Y: 1}}}
+	for !move.Process(w, 0) {
+	}
+	if loc := structs.PointToGridPoint(player.Position); loc != trap.GridPoint || ms.GetCreatureAt(loc) != player {
+		t.Fatalf("bad: player at %v", loc)
+	}
+	if player.Life != life-trap.Hazard.StepDamage || trap.IsHidden() {
+		t.Fatalf("bad: life %d, hidden %v", player.Life, trap.IsHidden())
+	}
+
+	// Ending the enemies' turn in the fire hurts the skeleton on it, but not the player
+	life, skeletonLife := player.Life, skeleton.Life
+	fire := ms.GetTileAt(structs.GridPoint{X: 5, Y: 1})
+	(&TurnChange{PlayersTurn: true}).Process(w, 0)
+	if skeleton.Life != skeletonLife-fire.Hazard.TurnEndDamage || player.Life != life {
+		t.Fatalf("bad: skeleton life %d, player life %d", skeleton.Life, player.Life)
+	}
+}
+
+func TestDetectHazards(t *testing.T) {
+	w, ms := newTestWorld(
+		"######",
+		"#.#^.#",
+		"#....#",
+		"#^...#",
+		"#...^#",
+	)
+	player := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+
+	// Only the trap in range that the player can see gets found; one is behind a wall
+	// and the other is too far away
+	ms.DetectHazards(player)
+	for _, expected := range []struct {
+		loc    structs.GridPoint
+		hidden bool
+	}{
+		{structs.GridPoint{X: 3, Y: 1}, true},
+		{structs.GridPoint{X: 1, Y: 3}, false},
+		{structs.GridPoint{X: 4, Y: 4}, true},
+	} {
+		if hidden := ms.GetTileAt(expected.loc).IsHidden(); hidden != expected.hidden {
+			t.Errorf("bad: trap at %v hidden %v, expected %v", expected.loc, hidden, expected.hidden)
+		}
+	}
+}
@@ -22,11 +22,36 @@ type InputSystem struct {
 	player *structs.Creature
 	PlayerID
 
+	// Which item in the pile under the player gets picked up next
+	pileIndex int
+
 	outgoing chan NetworkMessage
 }
 
 const ReadyKey = "ready"
 const ResetKey = "reset"
+const DebugKey = "debug"
+
+// Held down along with an inventory hotkey to drop the item, or pass it to the ally under the mouse
+const DropKey = "drop"
+const PassKey = "pass"
+
+// Cycles through the items in the pile under the player
+const NextItemKey = "nextitem"
+
+// Saves the game, if we're the host
+const SaveGameKey = "savegame"
+
+// Spend a level-up stat point on Str, Dex or Int
+const RaiseStrengthKey = "raisestr"
+const RaiseDexterityKey = "raisedex"
+const RaiseIntelligenceKey = "raiseint"
+
+var statPointKeys = map[string]string{
+	RaiseStrengthKey:     structs.StrengthStat,
+	RaiseDexterityKey:    structs.DexterityStat,
+	RaiseIntelligenceKey: structs.IntelligenceStat,
+}
 
 // New is the initialisation of the System
 func (input *InputSystem) New(w *ecs.World) {
@@ -36,6 +61,14 @@ func (input *InputSystem) New(w *ecs.World) {
 
 	engo.Input.RegisterButton(ReadyKey, engo.R)
 	engo.Input.RegisterButton(ResetKey, engo.F)
+	engo.Input.RegisterButton(DebugKey, engo.F12)
+	engo.Input.RegisterButton(SaveGameKey, engo.F5)
+	engo.Input.RegisterButton(DropKey, engo.LeftShift)
+	engo.Input.RegisterButton(PassKey, engo.LeftControl)
+	engo.Input.RegisterButton(NextItemKey, engo.Tab)
+	engo.Input.RegisterButton(RaiseStrengthKey, engo.F1)
+	engo.Input.RegisterButton(RaiseDexterityKey, engo.F2)
+	engo.Input.RegisterButton(RaiseIntelligenceKey, engo.F3)
 
 	engo.Input.RegisterButton(string(EquipmentHotkeys[0]), engo.G)
 	engo.Input.RegisterButton(string(EquipmentHotkeys[1]), engo.H)
@@ -48,6 +81,8 @@ func (input *InputSystem) New(w *ecs.World) {
 	engo.Input.RegisterButton(string(InventoryHotkeys[2]), engo.C)
 	engo.Input.RegisterButton(string(InventoryHotkeys[3]), engo.V)
 	engo.Input.RegisterButton(string(InventoryHotkeys[4]), engo.B)
+	engo.Input.RegisterButton(string(InventoryHotkeys[5]), engo.N)
+	engo.Input.RegisterButton(string(InventoryHotkeys[6]), engo.M)
 
 	engo.Input.RegisterButton(string(SkillHotkeys[0]), engo.One)
 	engo.Input.RegisterButton(string(SkillHotkeys[1]), engo.Two)
@@ -79,16 +114,45 @@ func (input *InputSystem) Update(dt float32) {
 		}
 	}
 
+	// Log the state of the map, along with our planned move if there is one
+	if engo.Input.Button(DebugKey).JustPressed() && input.mapSystem.Tiles != nil {
+		var path []structs.GridPoint
+		for _, action := range input.turn.PlayerActions[input.PlayerID] {
+			if move, ok := action.(*Move); ok {
+				path = move.Path
+			}
+		}
+		log.Info("Map state:\n" + input.mapSystem.Dump(path, true))
+	}
+
+	if engo.Input.Button(SaveGameKey).JustPressed() {
+		input.SaveGame()
+	}
+
 	var playerEffectivePos structs.GridPoint
 	if input.player != nil {
 		if input.player.Dead {
 			return
 		}
 
-		playerEffectivePos = structs.PointToGridPoint(input.player.SpaceComponent.Position)
-		if input.turn.PlayerMovingFirst(input.PlayerID) {
-			path := input.turn.PlayerActions[input.PlayerID][0].(*Move).Path
-			playerEffectivePos = path[len(path)-1]
+		playerEffectivePos = input.effectivePosition()
+	}
+
+	for key, stat := range statPointKeys {
+		if engo.Input.Button(key).JustPressed() && input.player != nil && input.player.StatPoints > 0 {
+			input.outgoing <- NetworkMessage{
+				Events: []Event{&SpendStatPoint{
+					PlayerID: input.PlayerID,
+					Stat:     stat,
+				}},
+			}
+		}
+	}
+
+	if engo.Input.Button(NextItemKey).JustPressed() && input.player != nil {
+		input.pileIndex++
+		if item, count := input.SelectedPileItem(); item != nil {
+			log.Infof("Selected %s (%d of %d)", item.DisplayName(), input.pileIndex%count+1, count)
 		}
 	}
 
@@ -99,24 +163,39 @@ func (input *InputSystem) Update(dt float32) {
 			Y: int(input.mouseTracker.MouseComponent.MouseY / structs.TileWidth),
 		}
 
-		if input.mapSystem.GetTileAt(gridPoint) != nil {
-			// Try to pick up an item if we'll be on top of it, otherwise try to move to the square
-			if items := input.mapSystem.GetItemsAt(gridPoint); len(items) > 0 && items[0].OnGround && playerEffectivePos.DistanceTo(gridPoint) == 0 {
+		if input.mapSystem.InBounds(gridPoint) && input.mapSystem.GetTileAt(gridPoint) != nil {
+			// Open or close a door next to us, try to pick up an item if we'll be on top of it,
+			// otherwise try to move to the square
+			if CanToggleDoor(playerEffectivePos, gridPoint, input.mapSystem) {
 				input.outgoing <- NetworkMessage{
 					Events: []Event{&PlayerAction{
 						PlayerID: input.PlayerID,
-						Action: &PickupItem{
-							ItemId:     items[0].NetworkID,
+						Action: &ToggleDoor{
 							CreatureId: input.player.NetworkID,
-							ItemName:   items[0].Name,
+							Location:   gridPoint,
 						},
 					}},
 				}
+			} else if item, _ := input.SelectedPileItem(); item != nil && playerEffectivePos.DistanceTo(gridPoint) == 0 {
+				if !input.player.HasRoomFor(item) {
+					log.Info("Tried to pick up an item with a full inventory")
+				} else {
+					input.outgoing <- NetworkMessage{
+						Events: []Event{&PlayerAction{
+							PlayerID: input.PlayerID,
+							Action: &PickupItem{
+								ItemId:     item.NetworkID,
+								CreatureId: input.player.NetworkID,
+								ItemName:   item.DisplayName(),
+							},
+						}},
+					}
+				}
 			} else {
 				start := input.mapSystem.GetTileAt(structs.PointToGridPoint(input.player.SpaceComponent.Position))
 				path := GetPath(start, input.mapSystem.GetTileAt(gridPoint), input.mapSystem.Tiles, input.mapSystem.CreatureLocations, TeamPlayer)
 
-				if len(path) <= input.player.GetEffectiveMovement() && len(path) > 1 {
+				if PathCost(path, input.mapSystem.Tiles) <= input.player.Stats().Movement && len(path) > 1 {
 					input.outgoing <- NetworkMessage{
 						Events: []Event{&PlayerAction{
 							PlayerID: input.PlayerID,
@@ -143,19 +222,94 @@ func (input *InputSystem) Update(dt float32) {
 		}
 	}
 
-	for i := 0; i < structs.InventorySize; i++ {
+	for i := 0; i < structs.MaxInventorySize; i++ {
 		if engo.Input.Button(string(InventoryHotkeys[i])).JustPressed() && input.turn.PlayersTurn && !input.turn.PlayerReady[input.PlayerID] {
-			if input.player.Inventory[i] != nil && input.player.CanEquipItem(input.player.Inventory[i]) {
+			item := input.player.InventoryItem(i)
+			if item == nil {
+				continue
+			}
+
+			if engo.Input.Button(DropKey).Down() {
 				input.outgoing <- NetworkMessage{
 					Events: []Event{&PlayerAction{
 						PlayerID: input.PlayerID,
-						Action: &EquipItem{
+						Action: &DropItem{
 							InventorySlot: i,
 							CreatureId:    input.player.NetworkID,
-							ItemName:      input.player.Inventory[i].Name,
+							ItemName:      item.DisplayName(),
 						}},
 					},
 				}
+				continue
+			}
+
+			if engo.Input.Button(PassKey).Down() {
+				gridPoint := structs.GridPoint{
+					X: int(input.mouseTracker.MouseComponent.MouseX / structs.TileWidth),
+					Y: int(input.mouseTracker.MouseComponent.MouseY / structs.TileWidth),
+				}
+				var ally *structs.Creature
+				if input.mapSystem.InBounds(gridPoint) {
+					ally = input.mapSystem.GetCreatureAt(gridPoint)
+				}
+				if ally != nil && CanPassItem(input.mapSystem, input.player.NetworkID, ally.NetworkID, i, &playerEffectivePos) {
+					input.outgoing <- NetworkMessage{
+						Events: []Event{&PlayerAction{
+							PlayerID: input.PlayerID,
+							Action: &PassItem{
+								InventorySlot: i,
+								CreatureId:    input.player.NetworkID,
+								TargetId:      ally.NetworkID,
+								ItemName:      item.DisplayName(),
+							}},
+						},
+					}
+				} else {
+					log.Info("Tried to pass an item to someone who can't take it")
+				}
+				continue
+			}
+
+			// Consumables get used, aimed at whatever's under the mouse if they need a target
+			if item.IsConsumable() {
+				gridPoint := structs.GridPoint{
+					X: int(input.mouseTracker.MouseComponent.MouseX / structs.TileWidth),
+					Y: int(input.mouseTracker.MouseComponent.MouseY / structs.TileWidth),
+				}
+				target := structs.SkillTarget{Location: gridPoint}
+				if !input.mapSystem.InBounds(gridPoint) {
+					target = structs.SkillTarget{}
+				} else if creature := input.mapSystem.GetCreatureAt(gridPoint); creature != nil && item.Use.Skill != "" && !structs.GetSkillData(item.Use.Skill).TargetsGround {
+					target.ID = creature.NetworkID
+				}
+				if CanUseItem(input.mapSystem, input.player.NetworkID, i, target, &playerEffectivePos) {
+					input.outgoing <- NetworkMessage{
+						Events: []Event{&PlayerAction{
+							PlayerID: input.PlayerID,
+							Action: &UseItem{
+								InventorySlot: i,
+								CreatureId:    input.player.NetworkID,
+								ItemName:      item.DisplayName(),
+								Target:        target,
+							}},
+						},
+					}
+				} else {
+					log.Info("Tried to use an item on an invalid target")
+				}
+				continue
+			}
+
+			// Anything else gets equipped, or the turn system tells us why it can't be
+			input.outgoing <- NetworkMessage{
+				Events: []Event{&PlayerAction{
+					PlayerID: input.PlayerID,
+					Action: &EquipItem{
+						InventorySlot: i,
+						CreatureId:    input.player.NetworkID,
+						ItemName:      item.DisplayName(),
+					}},
+				},
 			}
 		}
 	}
@@ -169,7 +323,7 @@ func (input *InputSystem) Update(dt float32) {
 						Action: &UnequipItem{
 							EquipSlot:  i,
 							CreatureId: input.player.NetworkID,
-							ItemName:   input.player.Equipment[i].Name,
+							ItemName:   input.player.Equipment[i].DisplayName(),
 						}},
 					},
 				}
@@ -212,4 +366,37 @@ func (input *InputSystem) Update(dt float32) {
 	}
 }
 
+// Returns where the player will be when their non-move action happens, after their move if
+// they've locked one in first
+func (input *InputSystem) effectivePosition() structs.GridPoint {
+	if input.turn.PlayerMovingFirst(input.PlayerID) {
+		path := input.turn.PlayerActions[input.PlayerID][0].(*Move).Path
+		return path[len(path)-1]
+	}
+	return structs.PointToGridPoint(input.player.SpaceComponent.Position)
+}
+
+// SelectedPileItem returns the item that clicking on the player's tile would pick up out of the
+// pile there, along with how many items are in the pile
+func (input *InputSystem) SelectedPileItem() (*structs.Item, int) {
+	if input.player == nil || input.mapSystem.Tiles == nil {
+		return nil, 0
+	}
+	items := input.mapSystem.GetItemsAt(input.effectivePosition())
+	if len(items) == 0 {
+		return nil, 0
+	}
+	return items[input.pileIndex%len(items)], len(items)
+}
+
+// Shows a message on the local player's screen
+func (input *InputSystem) showMessage(message string) {
+	for _, system := range input.mapSystem.world.Systems() {
+		switch sys := system.(type) {
+		case *UiSystem:
+			sys.ShowMessage(message)
+		}
+	}
+}
+
 func (*InputSystem) Remove(ecs.BasicEntity) {}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+
+	"engo.io/ecs"
+	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// Splits the experience for a kill evenly between the living players, with anything left
+// over going to the players with the lowest IDs
+func (ms *MapSystem) shareXP(xp int) {
+	var living []*structs.Creature
+	for _, id := range sortedPlayerIDs(ms) {
+		if player := ms.Players[id]; !player.Dead {
+			living = append(living, player)
+		}
+	}
+	if len(living) == 0 {
+		return
+	}
+
+	share, extra := xp/len(living), xp%len(living)
+	for i, player := range living {
+		amount := share
+		if i < extra {
+			amount++
+		}
+		if player.GainXP(amount) == 0 {
+			continue
+		}
+
+		log.Infof("Creature id %d reached level %d", player.NetworkID, player.Level)
+		for _, system := range ms.world.Systems() {
+			switch sys := system.(type) {
+			case *UiSystem:
+				if sys.input.player == player {
+					sys.ShowMessage(fmt.Sprintf("Reached level %d! F1-F3 to raise Str/Dex/Int", player.Level))
+				}
+			}
+		}
+	}
+}
+
+// Spends one of a player's level-up stat points on Str, Dex or Int
+type SpendStatPoint struct {
+	PlayerID
+	Stat string
+}
+
+func (s *SpendStatPoint) Process(w *ecs.World, dt float32) bool {
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			player, ok := sys.Players[s.PlayerID]
+			if !ok {
+				return true
+			}
+			if err := player.SpendStatPoint(s.Stat); err != nil {
+				reportActionError(w, player.NetworkID, err)
+			}
+		}
+	}
+	return true
+}
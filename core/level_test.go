@@ -0,0 +1,49 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestShareXP(t *testing.T) {
+	w, ms := newTestWorld(
+		"######",
+		"#....#",
+		"######",
+	)
+	first := addTestPlayer(w, ms, 0, "Warrior", structs.GridPoint{X: 1, Y: 1})
+	second := addTestPlayer(w, ms, 1, "Warrior", structs.GridPoint{X: 2, Y: 1})
+	dead := addTestPlayer(w, ms, 2, "Warrior", structs.GridPoint{X: 3, Y: 1})
+	dead.Dead = true
+
+	// Killing an enemy splits its experience between the living players, with the odd point
+	// going to the lowest player ID
+	skeleton := structs.NewCreature("Skeleton", structs.GridPoint{X: 4, Y: 1})
+	skeleton.XP = 25
+	AddCreature(w, skeleton)
+	ms.RemoveCreature(skeleton)
+	if first.Experience != 13 || second.Experience != 12 || dead.Experience != 0 {
+		t.Fatalf("bad: %d, %d, %d", first.Experience, second.Experience, dead.Experience)
+	}
+
+	// Enough experience levels both players up, giving them stat points and their level-up stats
+	maxLife, life := first.Stats().MaxLife, first.Life
+	ms.shareXP(2 * structs.XPForLevel(2))
+	for _, player := range []*structs.Creature{first, second} {
+		if player.Level != 2 || player.StatPoints != structs.StatPointsPerLevel {
+			t.Fatalf("bad: level %d, %d points", player.Level, player.StatPoints)
+		}
+	}
+	if first.Stats().MaxLife != maxLife+first.LevelUp.MaxLife || first.Life != life+first.LevelUp.MaxLife {
+		t.Fatalf("bad: life %d/%d", first.Life, first.Stats().MaxLife)
+	}
+
+	// Points can only be spent on str, dex or int
+	strength := first.Stats().Strength
+	(&SpendStatPoint{PlayerID: 0, Stat: structs.StrengthStat}).Process(w, 0)
+	(&SpendStatPoint{PlayerID: 0, Stat: "luck"}).Process(w, 0)
+	if first.Stats().Strength != strength+1 || first.StatPoints != structs.StatPointsPerLevel-1 {
+		t.Fatalf("bad: str %d, %d points", first.Stats().Strength, first.StatPoints)
+	}
+}
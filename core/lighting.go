@@ -14,7 +14,10 @@ const LIGHT_DECREASE = 20
 
 type LightSystem struct {
 	mapSystem *MapSystem
-	lights    map[*ecs.BasicEntity]LightSource
+	lights    map[uint64]LightSource
+
+	// Lights from skills, which only last until the end of the turn
+	temporary []ecs.BasicEntity
 
 	needsUpdate bool
 	timer       float32
@@ -23,6 +26,7 @@ type LightSystem struct {
 type LightSource interface {
 	GetLocation() structs.GridPoint
 	GetBrightness() uint8
+	GetRadius() int
 }
 
 type BasicLightSource struct {
@@ -30,24 +34,30 @@ type BasicLightSource struct {
 
 	// The starting brightness alpha value. 255 is full brightness
 	Brightness uint8
+
+	// The number of tiles the light reaches, 0 to base it on the brightness
+	Radius int
 }
 
 func (b *BasicLightSource) GetLocation() structs.GridPoint { return b.GridPoint }
 func (b *BasicLightSource) GetBrightness() uint8           { return b.Brightness }
+func (b *BasicLightSource) GetRadius() int                 { return b.Radius }
 
 type DynamicLightSource struct {
 	spaceComponent *common.SpaceComponent
 	Brightness     uint8
+	Radius         int
 }
 
 func (d *DynamicLightSource) GetLocation() structs.GridPoint {
 	return structs.PointToGridPoint(d.spaceComponent.Position)
 }
 func (d *DynamicLightSource) GetBrightness() uint8 { return d.Brightness }
+func (d *DynamicLightSource) GetRadius() int       { return d.Radius }
 
 // New is the initialisation of the System
 func (ls *LightSystem) New(w *ecs.World) {
-	ls.lights = make(map[*ecs.BasicEntity]LightSource)
+	ls.lights = make(map[uint64]LightSource)
 
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
@@ -56,12 +66,6 @@ func (ls *LightSystem) New(w *ecs.World) {
 		}
 	}
 
-	e := ecs.NewBasic()
-	ls.Add(&e, &BasicLightSource{
-		GridPoint:  structs.GridPoint{18, 3},
-		Brightness: 250,
-	})
-
 	ls.needsUpdate = true
 }
 
@@ -77,10 +81,17 @@ func (ls *LightSystem) Update(dt float32) {
 		}
 
 		// Increase the light of the tiles around the source in a diamond pattern,
-		// with the light strength fading with distance from the source.
+		// with the light strength fading with distance from the source. Tiles behind
+		// something that blocks light stay dark.
 		for _, light := range ls.lights {
-			radius := int((light.GetBrightness()-structs.MinBrightness)/LIGHT_DECREASE) + 1
+			brightness := int(light.GetBrightness())
+			radius := light.GetRadius()
+			if radius <= 0 {
+				radius = (brightness-structs.MinBrightness)/LIGHT_DECREASE + 1
+			}
+			decrease := imath.Max((brightness-structs.MinBrightness)/radius, 1)
 			//log.Infof("radius: %d", radius)
+			source := light.GetLocation()
 			for i := 0; i <= radius*2; i++ {
 				current := light.GetLocation()
 				current.X -= radius
@@ -88,12 +99,12 @@ func (ls *LightSystem) Update(dt float32) {
 
 				for j := 0; j <= radius*2; j++ {
 					//log.Infof("%d, %d distance to %d, %d: %d", current.X, current.Y, light.X, light.Y, current.distanceTo(&light.GridPoint))
-					if current.X >= 0 && current.X <= ls.mapSystem.MapWidth() && current.Y >= 0 && current.Y <= ls.mapSystem.MapHeight() {
+					if current.X >= 0 && current.X < ls.mapSystem.MapWidth() && current.Y >= 0 && current.Y < ls.mapSystem.MapHeight() {
 						dist := current.DistanceTo(light.GetLocation())
 
-						if dist <= radius {
+						if dist <= radius && ls.mapSystem.hasClearLine(source, current, ls.mapSystem.BlocksLight) {
 							if tile := ls.mapSystem.Tiles[current.X][current.Y]; tile != nil {
-								lightStrength := (radius - dist) * LIGHT_DECREASE
+								lightStrength := (radius - dist) * decrease
 								//log.Infof("lights at %d,%d updated to %d", current.X, current.Y, int(tile.Color.(color.Alpha).A) + lightStrength)
 								tile.Color = color.Alpha{uint8(imath.Min(int(tile.Color.(color.Alpha).A)+lightStrength, 250))}
 							}
@@ -111,10 +122,47 @@ func (ls *LightSystem) Update(dt float32) {
 }
 
 func (ls *LightSystem) Add(e *ecs.BasicEntity, light LightSource) {
-	ls.lights[e] = light
+	ls.lights[e.ID()] = light
 	ls.needsUpdate = true
 }
 
+// AddLight adds a light described in the data file that follows the given space component.
+// Lights with no brightness are ignored.
+func (ls *LightSystem) AddLight(e *ecs.BasicEntity, light structs.LightComponent, space *common.SpaceComponent) {
+	if !light.IsLit() {
+		return
+	}
+	ls.Add(e, &DynamicLightSource{
+		spaceComponent: space,
+		Brightness:     uint8(imath.Min(light.Brightness, 255)),
+		Radius:         light.Radius,
+	})
+}
+
+// AddTemporaryLight adds a light at the given location that lasts until ClearTemporaryLights is called
+func (ls *LightSystem) AddTemporaryLight(light structs.LightComponent, loc structs.GridPoint) {
+	if !light.IsLit() {
+		return
+	}
+	e := ecs.NewBasic()
+	ls.Add(&e, &BasicLightSource{
+		GridPoint:  loc,
+		Brightness: uint8(imath.Min(light.Brightness, 255)),
+		Radius:     light.Radius,
+	})
+	ls.temporary = append(ls.temporary, e)
+}
+
+func (ls *LightSystem) ClearTemporaryLights() {
+	for _, e := range ls.temporary {
+		ls.Remove(e)
+	}
+	ls.temporary = nil
+}
+
 func (ls *LightSystem) Remove(entity ecs.BasicEntity) {
-	delete(ls.lights, &entity)
+	if _, ok := ls.lights[entity.ID()]; ok {
+		delete(ls.lights, entity.ID())
+		ls.needsUpdate = true
+	}
 }
@@ -8,6 +8,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/kyhavlov/go-dnd/mapgen"
 	"github.com/kyhavlov/go-dnd/structs"
+	"math/rand"
 )
 
 // The map system tracks the tiles of the map
@@ -17,6 +18,12 @@ type MapSystem struct {
 
 	MapInfo *mapgen.Map
 
+	// How the game was started, kept for saving it
+	Start GameStart
+
+	// Seeded the same on every client, for anything on the map that needs to be random
+	Random *rand.Rand
+
 	Tiles [][]*structs.Tile
 
 	Creatures         map[structs.NetworkID]*structs.Creature
@@ -26,6 +33,9 @@ type MapSystem struct {
 	Items         map[structs.NetworkID]*structs.Item
 	ItemLocations [][][]*structs.Item
 
+	// Noises made since the enemies last planned their turn, cleared once they have
+	Noises []Noise
+
 	world *ecs.World
 }
 
@@ -69,6 +79,14 @@ func (ms *MapSystem) RemoveCreature(creature *structs.Creature) {
 	for i := 0; i < len(creature.Inventory); i++ {
 		ms.DropItem(creature, false, i, loc)
 	}
+	if creature.LootTable != "" {
+		for _, name := range structs.GetLootTable(creature.LootTable).Roll(ms.Random) {
+			AddItem(ms.world, structs.RollItem(name, loc, ms.Random))
+		}
+	}
+	if !creature.IsPlayerTeam && creature.XP > 0 {
+		ms.shareXP(creature.XP)
+	}
 
 	delete(ms.Creatures, creature.NetworkID)
 	ms.CreatureLocations[loc.X][loc.Y] = nil
@@ -79,15 +97,114 @@ func (ms *MapSystem) RemoveCreature(creature *structs.Creature) {
 			sys.Remove(creature.LifeIcon)
 			sys.Remove(creature.LifeDisplay)
 		case *UiSystem:
+			sys.Remove(creature.LifeDisplay)
+		case *LightSystem:
 			sys.Remove(creature.BasicEntity)
 		}
 	}
 }
 
+// ClearLevel removes the current level's tiles, enemies and items on the ground from the world,
+// leaving only the players and what they're carrying
+func (ms *MapSystem) ClearLevel() {
+	var entities []ecs.BasicEntity
+	for _, row := range ms.Tiles {
+		for _, tile := range row {
+			if tile != nil {
+				entities = append(entities, tile.BasicEntity)
+			}
+		}
+	}
+
+	var lifeDisplays []ecs.BasicEntity
+	for id, creature := range ms.Creatures {
+		if creature.IsPlayerTeam {
+			continue
+		}
+		entities = append(entities, creature.BasicEntity, creature.LifeIcon, creature.LifeDisplay)
+		lifeDisplays = append(lifeDisplays, creature.LifeDisplay)
+		delete(ms.Creatures, id)
+		delete(ms.SpaceComponents, id)
+		delete(ms.networkIds, &creature.BasicEntity)
+
+		// Anything the creature was carrying goes with it
+		for _, item := range append(creature.Equipment[:], creature.Inventory[:]...) {
+			if item != nil {
+				entities = append(entities, item.BasicEntity)
+				delete(ms.Items, item.NetworkID)
+				delete(ms.SpaceComponents, item.NetworkID)
+				delete(ms.networkIds, &item.BasicEntity)
+			}
+		}
+	}
+
+	for id, item := range ms.Items {
+		if item.OnGround {
+			entities = append(entities, item.BasicEntity)
+			delete(ms.Items, id)
+			delete(ms.SpaceComponents, id)
+			delete(ms.networkIds, &item.BasicEntity)
+		}
+	}
+
+	for _, system := range ms.world.Systems() {
+		switch sys := system.(type) {
+		case *common.RenderSystem:
+			for _, e := range entities {
+				sys.Remove(e)
+			}
+		case *LightSystem:
+			for _, e := range entities {
+				sys.Remove(e)
+			}
+			for _, light := range ms.MapInfo.Lights {
+				sys.Remove(light.BasicEntity)
+			}
+			sys.ClearTemporaryLights()
+		case *UiSystem:
+			for _, e := range lifeDisplays {
+				sys.Remove(e)
+			}
+		}
+	}
+
+	ms.Noises = nil
+}
+
+// PlayersOnStairs returns whether all the living players are standing on stairs
+func (ms *MapSystem) PlayersOnStairs() bool {
+	living := 0
+	for _, player := range ms.Players {
+		if player.Dead {
+			continue
+		}
+		living++
+		if tile := ms.GetTileAt(structs.PointToGridPoint(player.Position)); tile == nil || !tile.Stairs {
+			return false
+		}
+	}
+	return living > 0
+}
+
 func (ms *MapSystem) GetCreatureAt(point structs.GridPoint) *structs.Creature {
 	return ms.CreatureLocations[point.X][point.Y]
 }
 
+// Returns the closest walkable tile to the given point that no one is standing on, or the
+// point itself if there isn't one
+func (ms *MapSystem) nearestFreeTile(point structs.GridPoint) structs.GridPoint {
+	start := ms.GetTileAt(point)
+	if start == nil {
+		return point
+	}
+	for _, path := range GetReachableTiles(start, ms.MapWidth()*ms.MapHeight(), ms.Tiles, ms.CreatureLocations, TeamAny) {
+		if end := path[len(path)-1]; ms.GetCreatureAt(end) == nil {
+			return end
+		}
+	}
+	return point
+}
+
 func (ms *MapSystem) AddItem(item *structs.Item) {
 	item.SpaceComponent.Position.Add(engo.Point{structs.TileWidth / 4, structs.TileWidth / 4})
 	ms.SpaceComponents[item.NetworkID] = &item.SpaceComponent
@@ -97,14 +214,53 @@ func (ms *MapSystem) AddItem(item *structs.Item) {
 	ms.Items[item.NetworkID] = item
 }
 
+// AddCarriedItem keeps track of an item that starts off being carried by a creature
+func (ms *MapSystem) AddCarriedItem(item *structs.Item) {
+	ms.SpaceComponents[item.NetworkID] = &item.SpaceComponent
+	ms.networkIds[&item.BasicEntity] = item.NetworkID
+	ms.Items[item.NetworkID] = item
+}
+
+// StoreItem puts an item that's been taken off the ground into the creature's inventory,
+// adding it to a stack if there's one it fits on. Returns false if there's no room for it.
+func (ms *MapSystem) StoreItem(creature *structs.Creature, item *structs.Item) bool {
+	if slot := creature.StackSlot(item); slot != -1 {
+		creature.Inventory[slot].Count += item.Count
+		ms.RemoveItem(item)
+		return true
+	}
+	slot := creature.FreeInventorySlot()
+	if slot == -1 {
+		return false
+	}
+	creature.SetInventoryItem(slot, item)
+	return true
+}
+
+// RemoveItem takes an item out of the game for good, like when it's used up or added to a stack
+func (ms *MapSystem) RemoveItem(item *structs.Item) {
+	delete(ms.Items, item.NetworkID)
+	delete(ms.SpaceComponents, item.NetworkID)
+	delete(ms.networkIds, &item.BasicEntity)
+	for _, system := range ms.world.Systems() {
+		switch sys := system.(type) {
+		case *common.RenderSystem:
+			sys.Remove(item.BasicEntity)
+		case *LightSystem:
+			sys.Remove(item.BasicEntity)
+		}
+	}
+}
+
 func (ms *MapSystem) DropItem(creature *structs.Creature, equipment bool, slot int, dropPoint structs.GridPoint) {
 	var item *structs.Item
 	if equipment {
 		item = creature.Equipment[slot]
 		creature.Equipment[slot] = nil
+		creature.InvalidateStats()
 	} else {
-		item = creature.Inventory[slot]
-		creature.Inventory[slot] = nil
+		item = creature.InventoryItem(slot)
+		creature.SetInventoryItem(slot, nil)
 	}
 	if item == nil {
 		return
@@ -113,6 +269,27 @@ func (ms *MapSystem) DropItem(creature *structs.Creature, equipment bool, slot i
 	item.OnGround = true
 	item.RenderComponent.Hidden = false
 	ms.AddItem(item)
+
+	// Any light the item gives off stays where it was dropped
+	for _, system := range ms.world.Systems() {
+		switch sys := system.(type) {
+		case *LightSystem:
+			sys.Remove(item.BasicEntity)
+			sys.AddLight(&item.BasicEntity, item.Light, &item.SpaceComponent)
+		}
+	}
+}
+
+// Takes the item out of the pile on the ground it's in, if it's in one
+func (ms *MapSystem) removeFromPile(item *structs.Item) {
+	loc := structs.PointToGridPoint(item.Position)
+	pile := ms.ItemLocations[loc.X][loc.Y]
+	for i := range pile {
+		if pile[i] == item {
+			ms.ItemLocations[loc.X][loc.Y] = append(pile[:i:i], pile[i+1:]...)
+			return
+		}
+	}
 }
 
 func (ms *MapSystem) GetItemsAt(point structs.GridPoint) []*structs.Item {
@@ -128,6 +305,11 @@ func (ms *MapSystem) GetTileAt(point structs.GridPoint) *structs.Tile {
 	return ms.Tiles[point.X][point.Y]
 }
 
+// Returns whether the given point is inside the bounds of the map
+func (ms *MapSystem) InBounds(point structs.GridPoint) bool {
+	return point.X >= 0 && point.X < ms.MapWidth() && point.Y >= 0 && point.Y < ms.MapHeight()
+}
+
 func (ms *MapSystem) MapWidth() int {
 	return len(ms.Tiles)
 }
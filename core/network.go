@@ -4,7 +4,9 @@ import (
 	"encoding/gob"
 	"engo.io/ecs"
 	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/mapgen"
 	"github.com/kyhavlov/go-dnd/structs"
+	"io/ioutil"
 	"net"
 )
 
@@ -15,6 +17,9 @@ type NetworkMessage struct {
 	Sender    PlayerID
 	NewPlayer bool
 
+	// Who the sender wants to play as, sent once when joining a game
+	Join *JoinRequest
+
 	Events []Event
 }
 
@@ -126,7 +131,9 @@ func newServerRoom() *ServerRoom {
 	return room
 }
 
-func runServer(listener net.Listener, room *ServerRoom, players int) {
+func runServer(listener net.Listener, room *ServerRoom, players int, generator string, params mapgen.Params, mapFile []byte, host JoinRequest, game *GameSave) {
+	// The host is always player 0, and each client says who it's playing in its first message
+	joins := map[PlayerID]JoinRequest{0: host}
 	for i := 0; i < players; i++ {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -134,18 +141,51 @@ func runServer(listener net.Listener, room *ServerRoom, players int) {
 		}
 		log.Info("[server] new client connected from ", conn.RemoteAddr())
 		room.Join(conn)
+
+		joined := <-room.incoming
+		if joined.Join != nil {
+			joins[joined.Sender] = *joined.Join
+		}
 	}
 
-	// Send the game start event and create players/assign player IDs
-	events := []Event{GameStart{
-		RandomSeed:  34343421999,
-		PlayerCount: players + 1,
-	}}
+	// Send the game start event and create players/assign player IDs. When resuming a saved
+	// game, players who were in it get their characters back by name.
+	var events []Event
+	if game != nil {
+		resume := *game
+		resume.Players = nil
+		events = append(events, &ResumeGame{Save: resume, PlayerCount: players + 1})
+	} else {
+		events = append(events, GameStart{
+			RandomSeed:  34343421999,
+			PlayerCount: players + 1,
+			Generator:   generator,
+			Params:      params,
+			MapFile:     mapFile,
+		})
+	}
 	for i := 0; i < players+1; i++ {
+		join := joins[PlayerID(i)]
+		var resumed *structs.SavedCreature
+		if game != nil {
+			resumed = game.takePlayer(join.Name)
+		}
+		if resumed == nil {
+			join.validate(PlayerID(i))
+		}
 		events = append(events, &NewPlayer{
-			PlayerID: PlayerID(i),
+			PlayerID:  PlayerID(i),
+			Class:     join.Class,
+			Name:      join.Name,
+			Character: join.Character,
+			Resumed:   resumed,
 		})
 	}
+	if game != nil {
+		for _, player := range game.Players {
+			log.Warnf("[server] '%s' didn't rejoin the saved game", player.Character.Name)
+		}
+	}
 	room.incoming <- NetworkMessage{
 		Events: events,
 	}
@@ -156,9 +196,22 @@ func runServer(listener net.Listener, room *ServerRoom, players int) {
 	}
 }
 
-func StartServer(address string) *ServerRoom {
+// StartServer hosts a game on the given address, generating floors with the given generator and
+// params, with the host playing as the given character. If game isn't nil, the saved game is
+// resumed. Otherwise, if mapFile isn't empty, the first floor is loaded from that file instead of
+// being generated.
+func StartServer(address string, generator string, params mapgen.Params, mapFile string, host JoinRequest, game *GameSave) *ServerRoom {
 	room := newServerRoom()
 
+	var mapData []byte
+	if mapFile != "" {
+		var err error
+		mapData, err = ioutil.ReadFile(mapFile)
+		if err != nil {
+			log.Errorf("[server] Error reading map file, generating a map instead: %s", err)
+		}
+	}
+
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
 		log.Errorf("[server] Error binding on %s %s", address, err)
@@ -166,7 +219,7 @@ func StartServer(address string) *ServerRoom {
 		log.Infof("Hosting server at %v", listener.Addr())
 	}
 
-	runServer(listener, room, 1)
+	runServer(listener, room, 1, generator, params, mapData, host, game)
 
 	return room
 }
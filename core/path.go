@@ -42,22 +42,8 @@ func GetPath(start, goal *structs.Tile, tiles [][]*structs.Tile, creatures [][]*
 
 	path := make([]structs.GridPoint, 0)
 
-	// Define a function, sameTeam, for checking whether a creature is on a team we're allowed to move through
-	var sameTeam func(x, y int) bool
-	switch team {
-	case TeamAny:
-		sameTeam = func(x, y int) bool {
-			return true
-		}
-	case TeamPlayer:
-		sameTeam = func(x, y int) bool {
-			return creatures[x][y] == nil || creatures[x][y].IsPlayerTeam
-		}
-	case TeamEnemy:
-		sameTeam = func(x, y int) bool {
-			return creatures[x][y] == nil || !creatures[x][y].IsPlayerTeam
-		}
-	}
+	sameTeam := teamFilter(team, creatures)
+	walkable := pathWalkable(team)
 
 	for len(openSet) > 0 {
 		// Set current to the node in the open set with the lowest fScore
@@ -92,14 +78,17 @@ func GetPath(start, goal *structs.Tile, tiles [][]*structs.Tile, creatures [][]*
 		closedSet[current] = true
 
 		// Evaluate adjacent tiles to the current one
-		for _, neighbor := range getNeighbors(current, tiles, sameTeam) {
+		for _, neighbor := range getNeighbors(current, tiles, walkable, sameTeam) {
 			// Ignore the neighbors which are already evaluated.
 			if _, ok := closedSet[neighbor]; ok {
 				continue
 			}
 
-			// The distance from start to this neighbor
-			tentativeGScore := gScore[current] + 1
+			// The distance from start to this neighbor, going out of the way to avoid known hazards
+			tentativeGScore := gScore[current] + neighbor.GetMovementCost()
+			if knowsHazard(neighbor, team) {
+				tentativeGScore += HazardPathPenalty
+			}
 
 			if _, ok := openSet[neighbor]; !ok {
 				// New tile discovered that wasn't in the closed or open sets
@@ -121,29 +110,180 @@ func GetPath(start, goal *structs.Tile, tiles [][]*structs.Tile, creatures [][]*
 	return path
 }
 
+// GetReachableTiles finds the cheapest path to every tile that can be reached from start with a
+// path costing no more than maxCost (see PathCost). Each path includes the start tile, and paths
+// are ordered from cheapest to most expensive. Tiles occupied by another creature can be moved
+// through if the team allows it, but are left out of the result since they can't be stopped on.
+func GetReachableTiles(start *structs.Tile, maxCost int, tiles [][]*structs.Tile, creatures [][]*structs.Creature, team Team) [][]structs.GridPoint {
+	sameTeam := teamFilter(team, creatures)
+	cameFrom := map[*structs.Tile]*structs.Tile{start: nil}
+	costs := map[*structs.Tile]int{start: 1}
+	var paths [][]structs.GridPoint
+
+	// Tiles waiting to be visited, bucketed by the cost of getting to them
+	frontier := make([][]*structs.Tile, maxCost+1)
+	if maxCost >= 1 {
+		frontier[1] = []*structs.Tile{start}
+	}
+
+	for cost := 1; cost <= maxCost; cost++ {
+		for _, current := range frontier[cost] {
+			// Skip tiles we've since found a cheaper way to
+			if costs[current] != cost {
+				continue
+			}
+
+			if current == start || creatures[current.X][current.Y] == nil {
+				// Walk back through cameFrom to build the path to this tile
+				var path []structs.GridPoint
+				for tile := current; tile != nil; tile = cameFrom[tile] {
+					path = append([]structs.GridPoint{tile.GridPoint}, path...)
+				}
+				paths = append(paths, path)
+			}
+
+			for _, neighbor := range getNeighbors(current, tiles, isWalkable, sameTeam) {
+				nextCost := cost + neighbor.GetMovementCost()
+				if known, ok := costs[neighbor]; nextCost > maxCost || (ok && known <= nextCost) {
+					continue
+				}
+				costs[neighbor] = nextCost
+				cameFrom[neighbor] = current
+				frontier[nextCost] = append(frontier[nextCost], neighbor)
+			}
+		}
+	}
+
+	return paths
+}
+
+// PathCost returns how much movement it takes to follow a path. The start of the path counts
+// as 1, so a path over plain floor costs its length.
+func PathCost(path []structs.GridPoint, tiles [][]*structs.Tile) int {
+	if len(path) == 0 {
+		return 0
+	}
+	cost := 1
+	for _, point := range path[1:] {
+		cost += tiles[point.X][point.Y].GetMovementCost()
+	}
+	return cost
+}
+
+// LimitPath cuts the path short to the furthest point that can be reached with the given movement
+func LimitPath(path []structs.GridPoint, movement int, tiles [][]*structs.Tile) []structs.GridPoint {
+	for len(path) > 0 && PathCost(path, tiles) > movement {
+		path = path[:len(path)-1]
+	}
+	return path
+}
+
+// Distance is how far a tile is from the closest of the tiles a search started from
+type Distance struct {
+	From *structs.Tile
+	Cost int
+}
+
+// GetDistances finds the cost (see PathCost) of the cheapest path to every reachable tile from
+// whichever of the start tiles is closest to it, all in one search
+func GetDistances(starts []*structs.Tile, tiles [][]*structs.Tile, creatures [][]*structs.Creature, team Team) map[*structs.Tile]Distance {
+	sameTeam := teamFilter(team, creatures)
+	walkable := pathWalkable(team)
+	distances := make(map[*structs.Tile]Distance)
+
+	// Tiles waiting to be visited, bucketed by the cost of getting to them
+	frontier := make([][]*structs.Tile, 2)
+	for _, start := range starts {
+		if _, ok := distances[start]; !ok {
+			distances[start] = Distance{From: start, Cost: 1}
+			frontier[1] = append(frontier[1], start)
+		}
+	}
+
+	for cost := 1; cost < len(frontier); cost++ {
+		for _, current := range frontier[cost] {
+			// Skip tiles we've since found a cheaper way to
+			if distances[current].Cost != cost {
+				continue
+			}
+
+			for _, neighbor := range getNeighbors(current, tiles, walkable, sameTeam) {
+				nextCost := cost + neighbor.GetMovementCost()
+				if known, ok := distances[neighbor]; ok && known.Cost <= nextCost {
+					continue
+				}
+				distances[neighbor] = Distance{From: distances[current].From, Cost: nextCost}
+				for len(frontier) <= nextCost {
+					frontier = append(frontier, nil)
+				}
+				frontier[nextCost] = append(frontier[nextCost], neighbor)
+			}
+		}
+	}
+
+	return distances
+}
+
+// Returns a function for checking whether a creature is on a team we're allowed to move through
+func teamFilter(team Team, creatures [][]*structs.Creature) func(x, y int) bool {
+	switch team {
+	case TeamPlayer:
+		return func(x, y int) bool {
+			return creatures[x][y] == nil || creatures[x][y].IsPlayerTeam
+		}
+	case TeamEnemy:
+		return func(x, y int) bool {
+			return creatures[x][y] == nil || !creatures[x][y].IsPlayerTeam
+		}
+	default:
+		return func(x, y int) bool {
+			return true
+		}
+	}
+}
+
 func getEstimatedDistance(a, b *structs.Tile) int {
 	return imath.Abs(a.X-b.X) + imath.Abs(a.Y-b.Y)
 }
 
 // TODO: re-use the neighbors slice instead of allocating a new one every time we call this
-func getNeighbors(tile *structs.Tile, tiles [][]*structs.Tile, sameTeam func(x, y int) bool) []*structs.Tile {
+func getNeighbors(tile *structs.Tile, tiles [][]*structs.Tile, walkable func(*structs.Tile) bool, sameTeam func(x, y int) bool) []*structs.Tile {
 	neighbors := make([]*structs.Tile, 0)
 
-	if tile.X > 0 && tiles[tile.X-1][tile.Y] != nil && sameTeam(tile.X-1, tile.Y) {
+	if tile.X > 0 && walkable(tiles[tile.X-1][tile.Y]) && sameTeam(tile.X-1, tile.Y) {
 		neighbors = append(neighbors, tiles[tile.X-1][tile.Y])
 	}
 
-	if tile.X < len(tiles)-1 && tiles[tile.X+1][tile.Y] != nil && sameTeam(tile.X+1, tile.Y) {
+	if tile.X < len(tiles)-1 && walkable(tiles[tile.X+1][tile.Y]) && sameTeam(tile.X+1, tile.Y) {
 		neighbors = append(neighbors, tiles[tile.X+1][tile.Y])
 	}
 
-	if tile.Y > 0 && tiles[tile.X][tile.Y-1] != nil && sameTeam(tile.X, tile.Y-1) {
+	if tile.Y > 0 && walkable(tiles[tile.X][tile.Y-1]) && sameTeam(tile.X, tile.Y-1) {
 		neighbors = append(neighbors, tiles[tile.X][tile.Y-1])
 	}
 
-	if tile.Y < len(tiles[0])-1 && tiles[tile.X][tile.Y+1] != nil && sameTeam(tile.X, tile.Y+1) {
+	if tile.Y < len(tiles[0])-1 && walkable(tiles[tile.X][tile.Y+1]) && sameTeam(tile.X, tile.Y+1) {
 		neighbors = append(neighbors, tiles[tile.X][tile.Y+1])
 	}
 
 	return neighbors
 }
+
+// Returns which tiles a path for the team can go through. Players open doors themselves, so only
+// their paths stop at closed ones; enemies open them on the way (see GroupPlan.TrimPath).
+func pathWalkable(team Team) func(*structs.Tile) bool {
+	if team == TeamPlayer {
+		return isWalkable
+	}
+	return func(tile *structs.Tile) bool {
+		return isWalkable(tile) || isClosedDoor(tile)
+	}
+}
+
+func isWalkable(tile *structs.Tile) bool {
+	return tile != nil && tile.Walkable
+}
+
+func isClosedDoor(tile *structs.Tile) bool {
+	return tile != nil && !tile.Walkable && tile.Toggle != ""
+}
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// The score bonus for attacking from the opposite side of a target from an ally
+const flankBonus = 2
+
+// GroupPlan coordinates the enemies over a whole enemy turn. Destination tiles are reserved
+// as enemies plan their moves so they don't all head for the same spot, and melee enemies are
+// assigned their own tile next to a target to attack from, preferring to flank.
+type GroupPlan struct {
+	// Tiles that enemies have claimed as their destination this turn
+	reserved map[structs.GridPoint]structs.NetworkID
+
+	// The tile next to a target each melee enemy has been assigned to attack from
+	slots map[structs.NetworkID]attackSlot
+
+	// The player each enemy saw while planning, which it will go after
+	targets map[structs.NetworkID]structs.NetworkID
+
+	// How far each tile is from the closest living player, found once for the whole turn
+	playerDistances map[*structs.Tile]Distance
+
+	// The closed door each enemy's path stopped in front of, and the doors enemies are opening
+	doors   map[structs.NetworkID]structs.GridPoint
+	opening map[structs.GridPoint]bool
+}
+
+type attackSlot struct {
+	Target   structs.NetworkID
+	Location structs.GridPoint
+}
+
+// NewGroupPlan plans the enemy turn for the creatures in the given turn order. Each enemy
+// first updates what it can see and hear, then the melee enemies that can see a player get to
+// pick their attack positions, closest to the players first.
+func NewGroupPlan(turnOrder []int, sys *MapSystem) *GroupPlan {
+	plan := &GroupPlan{
+		reserved: make(map[structs.GridPoint]structs.NetworkID),
+		slots:    make(map[structs.NetworkID]attackSlot),
+		targets:  make(map[structs.NetworkID]structs.NetworkID),
+		doors:    make(map[structs.NetworkID]structs.GridPoint),
+		opening:  make(map[structs.GridPoint]bool),
+	}
+	plan.findPlayerDistances(sys)
+
+	var melee byDistance
+	for _, id := range turnOrder {
+		creature, ok := sys.Creatures[structs.NetworkID(id)]
+		if !ok {
+			continue
+		}
+		target := updateAwareness(creature, sys)
+		if target == nil {
+			continue
+		}
+		plan.targets[creature.NetworkID] = target.NetworkID
+		if _, ok := GetBehavior(creature.Behavior).(*BruteBehavior); ok {
+			tile := sys.GetTileAt(structs.PointToGridPoint(creature.Position))
+			melee = append(melee, creatureDistance{creature, plan.playerDistances[tile].Cost})
+		}
+	}
+
+	sort.Stable(melee)
+	for _, m := range melee {
+		plan.AttackSlot(m.creature, sys)
+	}
+
+	return plan
+}
+
+// Finds how far every tile is from the closest living player
+func (p *GroupPlan) findPlayerDistances(sys *MapSystem) {
+	var starts []*structs.Tile
+	for _, id := range sortedPlayerIDs(sys) {
+		player := sys.Players[id]
+		if player.Dead {
+			continue
+		}
+		tile := sys.GetTileAt(structs.PointToGridPoint(player.Position))
+		starts = append(starts, tile)
+	}
+	p.playerDistances = GetDistances(starts, sys.Tiles, sys.CreatureLocations, TeamAny)
+}
+
+// Target returns the player the creature saw while planning, or nil if it didn't see one or they've died since
+func (p *GroupPlan) Target(id structs.NetworkID, sys *MapSystem) *structs.Creature {
+	targetID, ok := p.targets[id]
+	if !ok {
+		return nil
+	}
+	if target, alive := sys.Creatures[targetID]; alive && !target.Dead {
+		return target
+	}
+	return nil
+}
+
+// Reserve claims the given tile as the destination of a creature
+func (p *GroupPlan) Reserve(id structs.NetworkID, loc structs.GridPoint) {
+	p.reserved[loc] = id
+}
+
+// IsReserved returns whether another creature has claimed the given tile this turn
+func (p *GroupPlan) IsReserved(id structs.NetworkID, loc structs.GridPoint) bool {
+	owner, ok := p.reserved[loc]
+	return ok && owner != id
+}
+
+// AttackSlot returns the tile next to a target the creature should attack from, assigning one if
+// it doesn't have one yet. Returns false if there's no free tile next to any player it can reach.
+func (p *GroupPlan) AttackSlot(creature *structs.Creature, sys *MapSystem) (attackSlot, bool) {
+	if slot, ok := p.slots[creature.NetworkID]; ok {
+		if target, alive := sys.Creatures[slot.Target]; alive && !target.Dead {
+			return slot, true
+		}
+		delete(p.slots, creature.NetworkID)
+	}
+
+	// Find how far every tile is from the creature once, rather than a path to each tile
+	creatureTile := sys.GetTileAt(structs.PointToGridPoint(creature.Position))
+	distances := GetDistances([]*structs.Tile{creatureTile}, sys.Tiles, sys.CreatureLocations, TeamEnemy)
+	var best attackSlot
+	bestScore := 9999
+	found := false
+	for _, id := range sortedPlayerIDs(sys) {
+		player := sys.Players[id]
+		if player.Dead {
+			continue
+		}
+		targetLoc := structs.PointToGridPoint(player.Position)
+		for _, neighbor := range getNeighbors(sys.GetTileAt(targetLoc), sys.Tiles, isWalkable, func(x, y int) bool { return true }) {
+			loc := neighbor.GridPoint
+			if occupant := sys.GetCreatureAt(loc); (occupant != nil && occupant != creature) || p.IsReserved(creature.NetworkID, loc) {
+				continue
+			}
+			distance, ok := distances[neighbor]
+			if !ok {
+				continue
+			}
+
+			score := distance.Cost
+			if p.isFlanking(creature, player, loc, sys) {
+				score -= flankBonus
+			}
+			if score < bestScore {
+				bestScore = score
+				best = attackSlot{Target: player.NetworkID, Location: loc}
+				found = true
+			}
+		}
+	}
+
+	if found {
+		p.slots[creature.NetworkID] = best
+		p.Reserve(creature.NetworkID, best.Location)
+	}
+	return best, found
+}
+
+// Returns whether an ally of the creature is attacking the target from the opposite side of loc
+func (p *GroupPlan) isFlanking(creature, target *structs.Creature, loc structs.GridPoint, sys *MapSystem) bool {
+	targetLoc := structs.PointToGridPoint(target.Position)
+	opposite := structs.GridPoint{
+		X: 2*targetLoc.X - loc.X,
+		Y: 2*targetLoc.Y - loc.Y,
+	}
+	if !sys.InBounds(opposite) {
+		return false
+	}
+	if ally := sys.GetCreatureAt(opposite); ally != nil && ally != creature && ally.IsPlayerTeam == creature.IsPlayerTeam {
+		return true
+	}
+	for id, slot := range p.slots {
+		if id != creature.NetworkID && slot.Target == target.NetworkID && slot.Location == opposite {
+			return true
+		}
+	}
+	return false
+}
+
+// TrimPath cuts the path short so that it ends on a tile that isn't occupied or reserved by
+// another creature, then reserves that tile. Paths stop in front of the first closed door on
+// them, for the creature to open. Returns nil if the creature can't move at all.
+func (p *GroupPlan) TrimPath(creature *structs.Creature, path []structs.GridPoint, sys *MapSystem) []structs.GridPoint {
+	delete(p.doors, creature.NetworkID)
+	for i := 1; i < len(path); i++ {
+		if isClosedDoor(sys.GetTileAt(path[i])) {
+			p.doors[creature.NetworkID] = path[i]
+			path = path[:i]
+			break
+		}
+	}
+
+	for len(path) > 1 {
+		end := path[len(path)-1]
+		occupant := sys.GetCreatureAt(end)
+		if (occupant == nil || occupant == creature) && !p.IsReserved(creature.NetworkID, end) {
+			p.Reserve(creature.NetworkID, end)
+			return path
+		}
+		path = path[:len(path)-1]
+	}
+	p.Reserve(creature.NetworkID, structs.PointToGridPoint(creature.Position))
+	return nil
+}
+
+// OpenDoor returns the closed door the creature's path stopped in front of, if it ends up next to
+// it and no one else is opening it this turn (which would shut it again)
+func (p *GroupPlan) OpenDoor(id structs.NetworkID, loc structs.GridPoint) (structs.GridPoint, bool) {
+	door, ok := p.doors[id]
+	if !ok || p.opening[door] || loc.DistanceTo(door) != 1 {
+		return structs.GridPoint{}, false
+	}
+	p.opening[door] = true
+	return door, true
+}
+
+// Returns the IDs of the players in order, so planning doesn't depend on map iteration order
+func sortedPlayerIDs(sys *MapSystem) []PlayerID {
+	var ids []int
+	for id := range sys.Players {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	sorted := make([]PlayerID, len(ids))
+	for i, id := range ids {
+		sorted[i] = PlayerID(id)
+	}
+	return sorted
+}
+
+type creatureDistance struct {
+	creature *structs.Creature
+	distance int
+}
+
+type byDistance []creatureDistance
+
+func (d byDistance) Len() int           { return len(d) }
+func (d byDistance) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDistance) Less(i, j int) bool { return d[i].distance < d[j].distance }
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"engo.io/ecs"
+	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/mapgen"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// Where the host's game is saved to when they press the save key
+var GameSavePath = filepath.Join(structs.SaveDir, "game.json")
+
+// GameSave is the state of a game in progress, saved at the start of a players' turn. The floor
+// is made again the same way it was the first time, then everything that's changed on it since
+// is put back from the save.
+type GameSave struct {
+	DataVersion int `json:"data_version"`
+
+	// How the game was started, and which floor the players are on
+	Start GameStart `json:"start"`
+	Floor int       `json:"floor"`
+
+	// The state of the floor's random source
+	RandomSeed  int64  `json:"random_seed"`
+	RandomDraws uint64 `json:"random_draws"`
+
+	Tiles     []structs.SavedTile     `json:"tiles"`
+	Creatures []structs.SavedCreature `json:"creatures"`
+	Items     []structs.GroundItem    `json:"items"`
+
+	// The living players, matched back up with returning players by their character's name
+	Players []structs.SavedCreature `json:"players"`
+}
+
+// NewGameSave records the state of the game. Games can only be saved at the start of a
+// players' turn, before anything's happened in it; actions players have planned aren't saved.
+func NewGameSave(ms *MapSystem, ts *TurnSystem, es *EventSystem) (*GameSave, error) {
+	if ms.MapInfo == nil {
+		return nil, fmt.Errorf("The game hasn't started yet")
+	}
+	if !ts.PlayersTurn || len(es.activeEvents) > 0 {
+		return nil, fmt.Errorf("Can't save the game until the players' turn")
+	}
+	for id, ready := range ts.PlayerReady {
+		if ready {
+			return nil, fmt.Errorf("Can't save the game after player %d is ready", id)
+		}
+	}
+
+	save := &GameSave{
+		DataVersion: structs.DataVersion(),
+		Start:       ms.Start,
+		Floor:       ms.MapInfo.Floor,
+		RandomSeed:  ms.MapInfo.RandomSource.StartSeed,
+		RandomDraws: ms.MapInfo.RandomSource.Draws,
+	}
+
+	for x := range ms.Tiles {
+		for y := range ms.Tiles[x] {
+			if tile := ms.Tiles[x][y]; tile != nil {
+				save.Tiles = append(save.Tiles, structs.NewSavedTile(tile))
+			}
+			for _, item := range ms.ItemLocations[x][y] {
+				save.Items = append(save.Items, structs.NewGroundItem(item))
+			}
+		}
+	}
+
+	// Keep the creatures in the order they were added, which is the order they act in
+	var ids []int
+	for id, creature := range ms.Creatures {
+		if !creature.IsPlayerTeam {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		save.Creatures = append(save.Creatures, structs.NewSavedCreature(ms.Creatures[structs.NetworkID(id)]))
+	}
+
+	for _, id := range sortedPlayerIDs(ms) {
+		player := ms.Players[id]
+		if player.Dead {
+			continue
+		}
+		if player.Character == "" {
+			log.Warnf("Player %d doesn't have a name, so they can't rejoin the saved game", id)
+			continue
+		}
+		save.Players = append(save.Players, structs.NewSavedCreature(player))
+	}
+
+	return save, nil
+}
+
+// WriteGameSave writes the saved game to the file at the given path
+func WriteGameSave(path string, save *GameSave) error {
+	bytes, err := json.Marshal(save)
+	if err != nil {
+		return fmt.Errorf("Error encoding saved game: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Error making save directory: %s", err)
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
+}
+
+// LoadGameSave reads the saved game at the given path, checking it against the data file
+func LoadGameSave(path string) (*GameSave, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading saved game: %s", err)
+	}
+	save := &GameSave{}
+	if err := json.Unmarshal(bytes, save); err != nil {
+		return nil, fmt.Errorf("Error parsing saved game: %s", err)
+	}
+	if err := save.Validate(); err != nil {
+		return nil, err
+	}
+	return save, nil
+}
+
+// Validate returns an error if anything in the saved game doesn't match the data file. Unlike
+// characters, saved games can't be upgraded to a newer version of the data.
+func (save *GameSave) Validate() error {
+	if save.DataVersion != structs.DataVersion() {
+		return fmt.Errorf("Game was saved with data version %d, not %d", save.DataVersion, structs.DataVersion())
+	}
+	for _, tile := range save.Tiles {
+		if err := tile.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range save.Creatures {
+		if save.Creatures[i].Character != nil {
+			return fmt.Errorf("Saved player '%s' is in with the creatures", save.Creatures[i].Character.Name)
+		}
+		if err := save.Creatures[i].Validate(); err != nil {
+			return err
+		}
+		// Behaviors live here rather than in the data file, so structs can't check them
+		if behavior := save.Creatures[i].Behavior; behavior != "" && behaviors[behavior] == nil {
+			return fmt.Errorf("%s has unrecognized behavior: '%s'", save.Creatures[i].Name, behavior)
+		}
+	}
+	for _, item := range save.Items {
+		if err := item.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range save.Players {
+		if save.Players[i].Character == nil {
+			return fmt.Errorf("Saved player %d has no character", i)
+		}
+		if err := save.Players[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Takes the named player out of the save, returning nil if they're not in it
+func (save *GameSave) takePlayer(name string) *structs.SavedCreature {
+	for i := range save.Players {
+		if name != "" && save.Players[i].Character.Name == name {
+			player := save.Players[i]
+			save.Players = append(save.Players[:i:i], save.Players[i+1:]...)
+			return &player
+		}
+	}
+	return nil
+}
+
+// Makes the saved floor, with everything on it put back the way it was
+func (save *GameSave) level() (*mapgen.Map, error) {
+	level := save.Start.firstFloor()
+	if save.Floor > 1 {
+		level = mapgen.Generate(level.Generator, level.Params, level.Seed, save.Floor)
+	}
+
+	level.Tiles = nil
+	for _, tile := range save.Tiles {
+		level.Tiles = append(level.Tiles, tile.Tile())
+	}
+	level.Creatures = nil
+	for i := range save.Creatures {
+		creature, err := save.Creatures[i].Creature()
+		if err != nil {
+			return nil, err
+		}
+		level.Creatures = append(level.Creatures, creature)
+	}
+	level.Items = nil
+	for _, item := range save.Items {
+		level.Items = append(level.Items, item.Item())
+	}
+
+	level.RandomSource = mapgen.NewRandomSource(save.RandomSeed, save.RandomDraws)
+	level.Random = rand.New(level.RandomSource)
+	return level, nil
+}
+
+// Starts a game from a save instead of a new dungeon. The players are added afterwards
+// with NewPlayer, the same as in a new game.
+type ResumeGame struct {
+	Save        GameSave
+	PlayerCount int
+}
+
+func (rg *ResumeGame) Process(w *ecs.World, dt float32) bool {
+	level, err := rg.Save.level()
+	if err != nil {
+		log.Fatalf("Error resuming saved game: %s", err)
+	}
+	log.Infof("Resuming saved game on floor %d", rg.Save.Floor)
+	startLevel(w, rg.Save.Start, level, rg.PlayerCount)
+	return true
+}
+
+// SaveGame saves the game to GameSavePath, if we're the host
+func (input *InputSystem) SaveGame() {
+	if input.turn.event.serverRoom == nil {
+		input.showMessage("Only the host can save the game")
+		return
+	}
+	save, err := NewGameSave(input.mapSystem, input.turn, input.turn.event)
+	if err == nil {
+		err = WriteGameSave(GameSavePath, save)
+	}
+	if err != nil {
+		log.Errorf("Error saving game: %s", err)
+		input.showMessage(err.Error())
+		return
+	}
+	log.Infof("Saved game to %s", GameSavePath)
+	input.showMessage("Game saved")
+}
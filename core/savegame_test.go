@@ -0,0 +1,23 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestGameSaveBehavior(t *testing.T) {
+	skeleton := structs.NewCreature("Skeleton Archer", structs.GridPoint{X: 1, Y: 1})
+	save := &GameSave{
+		DataVersion: structs.DataVersion(),
+		Creatures:   []structs.SavedCreature{structs.NewSavedCreature(skeleton)},
+	}
+	if err := save.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	save.Creatures[0].Behavior = "dancer"
+	if err := save.Validate(); err == nil {
+		t.Fatal("bad: loaded a creature with an unknown behavior")
+	}
+}
@@ -1,13 +1,14 @@
 package core
 
 import (
+	"flag"
 	"net"
-	"os"
 
 	"engo.io/ecs"
 	"engo.io/engo"
 	"engo.io/engo/common"
 	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/mapgen"
 	"github.com/kyhavlov/go-dnd/structs"
 )
 
@@ -16,6 +17,20 @@ type DungeonScene struct {
 	// The server room to use if we're the server, nil if we're not
 	serverRoom *ServerRoom
 
+	// The map generator and params to make the dungeon with, if we're the server
+	Generator string
+	Params    mapgen.Params
+
+	// The class to play as, from the classes in the data file, and the name of the character
+	// to load and save. Characters without a name aren't saved.
+	Class string
+	Name  string
+
+	// The saved game to resume, if we're the server
+	Resume string
+
+	input *InputSystem
+
 	// Channels to send/receive network messages
 	incoming chan NetworkMessage
 	outgoing chan NetworkMessage
@@ -33,10 +48,6 @@ func (*DungeonScene) Preload() {
 	if err := engo.Files.Load("fonts/Gamegirl.ttf"); err != nil {
 		panic(err)
 	}
-
-	if err := structs.LoadItems(); err != nil {
-		panic(err)
-	}
 }
 
 // Setup is called before the main loop starts. It allows you
@@ -52,6 +63,8 @@ func (scene *DungeonScene) Setup(world *ecs.World) {
 		turn:      turn,
 	}
 
+	scene.input = input
+
 	ui := &UiSystem{
 		input: input,
 	}
@@ -87,8 +100,26 @@ func (scene *DungeonScene) Setup(world *ecs.World) {
 // Then, hook the server's incoming channel to both our scene's outgoing and incoming channels
 // so that we can send our own actions directly to the server's input channel
 func (scene *DungeonScene) Start() {
-	if len(os.Args) > 1 && os.Args[1] == "server" {
-		serverRoom := StartServer(":8999")
+	// The data file is loaded first, since the server checks classes and saved characters against it
+	if err := structs.LoadItems(); err != nil {
+		panic(err)
+	}
+	join := NewJoinRequest(scene.Name, scene.Class)
+
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "server" {
+		mapFile := ""
+		if len(args) > 1 {
+			mapFile = args[1]
+		}
+		var game *GameSave
+		if scene.Resume != "" {
+			var err error
+			if game, err = LoadGameSave(scene.Resume); err != nil {
+				log.Fatalf("Error loading saved game: %s", err)
+			}
+		}
+		serverRoom := StartServer(":8999", scene.Generator, scene.Params, mapFile, join, game)
 		scene.incoming = serverRoom.incoming
 		scene.outgoing = serverRoom.incoming
 		scene.serverRoom = serverRoom
@@ -101,9 +132,17 @@ func (scene *DungeonScene) Start() {
 			log.Info("Connected to server at ", conn.RemoteAddr())
 		}
 		client := NewClient(conn)
+		client.outgoing <- NetworkMessage{Join: &join}
 		gameStart := <-client.incoming
 		client.incoming <- gameStart
 		scene.incoming = client.incoming
 		scene.outgoing = client.outgoing
 	}
 }
+
+// Exit saves the local player's character when the game is closed
+func (scene *DungeonScene) Exit() {
+	if scene.input != nil {
+		scene.input.SaveCharacter()
+	}
+}
@@ -9,22 +9,44 @@ import (
 func GetSkillTargetLocation(target structs.SkillTarget, sys *MapSystem) structs.GridPoint {
 	loc := target.Location
 	if target.ID != 0 {
-		targetCreature := sys.Creatures[target.ID]
-		loc = structs.PointToGridPoint(targetCreature.Position)
+		if targetCreature, ok := sys.Creatures[target.ID]; ok {
+			loc = structs.PointToGridPoint(targetCreature.Position)
+		}
 	}
 	return loc
 }
 
 func CanUseSkill(name string, sys *MapSystem, sourceID structs.NetworkID, target structs.SkillTarget, sourceLoc *structs.GridPoint) bool {
+	source, ok := sys.Creatures[sourceID]
+	if !ok || source.Stats().Stamina < structs.GetSkillData(name).StaminaCost {
+		return false
+	}
+	return canTargetSkill(name, sys, sourceID, target, sourceLoc)
+}
+
+// Returns whether the skill can reach the target from the source, ignoring its stamina cost
+func canTargetSkill(name string, sys *MapSystem, sourceID structs.NetworkID, target structs.SkillTarget, sourceLoc *structs.GridPoint) bool {
 	skill := structs.GetSkillData(name)
-	source := sys.Creatures[sourceID]
+	source, ok := sys.Creatures[sourceID]
+	if !ok {
+		return false
+	}
 	a := structs.PointToGridPoint(source.SpaceComponent.Position)
 	if sourceLoc != nil {
 		a = *sourceLoc
 	}
 	b := GetSkillTargetLocation(target, sys)
 
-	if source.Stamina < skill.StaminaCost {
+	// The target creature may have died before the skill could be used
+	if _, ok := sys.Creatures[target.ID]; target.ID != 0 && !ok {
+		return false
+	}
+
+	// Skills can't be used through walls, or aimed at somewhere that isn't open ground
+	if !sys.InBounds(b) || !sys.HasLineOfSight(a, b) {
+		return false
+	}
+	if tile := sys.GetTileAt(b); skill.TargetsGround && (tile == nil || tile.BlocksSight) {
 		return false
 	}
 
@@ -82,19 +104,22 @@ func GetSkillTargets(name string, sys *MapSystem, sourceID structs.NetworkID, ta
 		})
 	}
 
-	// Add extra targets in radius
+	// Add extra targets in radius, leaving out anything on the other side of a wall
 	if radius, ok := skill.Effects[structs.AoeEffect]; ok {
 		for i := 0; i < radius*2+1; i++ {
 			for j := 0; j < radius*2+1; j++ {
-				targets = append(targets, structs.GridPoint{
+				point := structs.GridPoint{
 					X: targetLoc.X - radius + i,
 					Y: targetLoc.Y - radius + j,
-				})
+				}
+				if sys.InBounds(point) && sys.HasLineOfSight(targetLoc, point) {
+					targets = append(targets, point)
+				}
 			}
 		}
 	}
 
-	// Add extra targets from piercing
+	// Add extra targets from piercing, stopping at the first wall
 	if pierceDistance, ok := skill.Effects[structs.PierceEffect]; ok {
 		for i := 1; i <= pierceDistance; i++ {
 			xDiff := targetLoc.X - sourceLoc.X
@@ -105,40 +130,91 @@ func GetSkillTargets(name string, sys *MapSystem, sourceID structs.NetworkID, ta
 			if yDiff != 0 {
 				yDiff = imath.Abs(yDiff) / yDiff
 			}
-			targets = append(targets, structs.GridPoint{
+			point := structs.GridPoint{
 				X: targetLoc.X + i*xDiff,
 				Y: targetLoc.Y + i*yDiff,
-			})
+			}
+			if sys.BlocksSight(point) {
+				break
+			}
+			targets = append(targets, point)
 		}
 	}
 
 	return targets
 }
 
+// Returns the amount of damage (or healing, for heal skills) the source creature does with the skill
+func GetSkillDamage(skill structs.Skill, source *structs.Creature) int {
+	stats := source.Stats()
+	damage := skill.Damage
+	damage += int(skill.DamageBonuses.Str * float64(stats.Strength))
+	damage += int(skill.DamageBonuses.Dex * float64(stats.Dexterity))
+	damage += int(skill.DamageBonuses.Int * float64(stats.Intelligence))
+	return damage
+}
+
 func PerformSkillActions(name string, sys *MapSystem, sourceID structs.NetworkID, target structs.SkillTarget) {
+	source := sys.Creatures[sourceID]
+	castSkill(name, sys, sourceID, target)
+	source.Stamina -= structs.GetSkillData(name).StaminaCost
+}
+
+// Does everything a skill does to its targets, without taking its stamina cost from the source
+func castSkill(name string, sys *MapSystem, sourceID structs.NetworkID, target structs.SkillTarget) {
 	// Get skill data and source creature
 	skill := structs.GetSkillData(name)
 	source := sys.Creatures[sourceID]
 
 	targetLocs := GetSkillTargets(name, sys, sourceID, target, nil)
+
+	// Light up the target area if the skill gives off light
+	if skill.Light.IsLit() && len(targetLocs) > 0 {
+		for _, system := range sys.world.Systems() {
+			switch lights := system.(type) {
+			case *LightSystem:
+				lights.AddTemporaryLight(skill.Light, GetSkillTargetLocation(target, sys))
+			}
+		}
+	}
 	var targets []*structs.Creature
 	for _, loc := range targetLocs {
+		if !sys.InBounds(loc) {
+			continue
+		}
 		if creature := sys.GetCreatureAt(loc); creature != nil {
 			targets = append(targets, creature)
 		}
 	}
 
+	// Using a skill makes noise that enemies nearby will hear
+	sourceLoc := structs.PointToGridPoint(source.Position)
+	noise := skill.Noise
+	if noise == 0 {
+		noise = DefaultSkillNoise
+	}
+	sys.MakeNoise(sourceLoc, noise)
+
 	for _, t := range targets {
-		damage := skill.Damage
-		damage += int(skill.DamageBonuses.Str * float64(source.GetEffectiveStrength()))
-		damage += int(skill.DamageBonuses.Dex * float64(source.GetEffectiveDexterity()))
-		damage += int(skill.DamageBonuses.Int * float64(source.GetEffectiveIntelligence()))
+		damage := GetSkillDamage(skill, source)
+		if skill.HasTag(structs.HealTag) {
+			t.Life = imath.Min(t.Life+damage, t.Stats().MaxLife)
+			log.Infof("Creature id %d healed %d from %s, at %d life now", t.NetworkID, damage, name, t.Life)
+			continue
+		}
 		t.Life -= damage
 		log.Infof("Creature id %d took %d damage from %s, at %d life now", t.NetworkID, damage, name, t.Life)
+
+		// Enemies know exactly where an attack came from, and cry out so others hear it too
+		if !t.IsPlayerTeam && source.IsPlayerTeam {
+			t.Awareness = structs.Hunting
+			t.LastKnownLoc = sourceLoc
+		}
+		sys.MakeNoise(structs.PointToGridPoint(t.Position), PainNoise)
 		if t.Life <= 0 {
 			sys.RemoveCreature(t)
+		} else {
+			CheckBossPhases(t, sys)
 		}
 	}
-
-	source.Stamina -= skill.StaminaCost
 }
@@ -3,6 +3,7 @@ package core
 import (
 	"engo.io/ecs"
 	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/structs"
 )
 
 type TurnSystem struct {
@@ -14,6 +15,7 @@ type TurnSystem struct {
 	ui    *UiSystem
 
 	enemyTurnOrder []int
+	enemyPlan      *GroupPlan
 }
 
 func (ts *TurnSystem) IsPlayerReady(id PlayerID) bool {
@@ -43,6 +45,25 @@ func (ts *TurnSystem) PlayerMovingFirst(id PlayerID) bool {
 	}
 }
 
+// ValidAction returns why an action can't be locked in for a player who'll be at sourceLoc
+// when it happens, or nil if it can. Actions are checked again when they're performed, since
+// things can change in the meantime.
+func (ts *TurnSystem) ValidAction(mapSystem *MapSystem, action Event, sourceLoc structs.GridPoint) error {
+	switch action := action.(type) {
+	case *PickupItem:
+		return action.validate(mapSystem, &sourceLoc)
+	case *EquipItem:
+		return action.validate(mapSystem)
+	case *UnequipItem:
+		return action.validate(mapSystem)
+	case *DropItem:
+		return action.validate(mapSystem)
+	case *PassItem:
+		return action.validate(mapSystem, &sourceLoc)
+	}
+	return nil
+}
+
 func (ts *TurnSystem) New(w *ecs.World) {
 	ts.PlayerActions = make(map[PlayerID][]Event)
 	ts.PlayerReady = make(map[PlayerID]bool)
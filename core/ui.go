@@ -12,7 +12,7 @@ import (
 )
 
 const EquipmentHotkeys = "GHJKL"
-const InventoryHotkeys = "ZXCVB"
+const InventoryHotkeys = "ZXCVBNM"
 const SkillHotkeys = "1234567890"
 
 type UiElement struct {
@@ -31,24 +31,39 @@ type DynamicText struct {
 }
 
 type UiSystem struct {
-	dynamicTexts     map[*ecs.BasicEntity]*DynamicText
+	dynamicTexts     map[uint64]*DynamicText
 	actionIndicators map[PlayerID][]*UiElement
 
 	equipmentFrames  [structs.EquipmentSlots]*common.SpaceComponent
 	equipmentDisplay [structs.EquipmentSlots]*ecs.BasicEntity
 
-	inventoryFrames  [structs.InventorySize]*common.SpaceComponent
-	inventoryDisplay [structs.InventorySize]*ecs.BasicEntity
+	// Frames past the player's inventory capacity are hidden until they get a bigger bag
+	inventoryFrames  [structs.MaxInventorySize]*UiElement
+	inventoryHotkeys [structs.MaxInventorySize]*UiElement
+	inventoryCounts  [structs.MaxInventorySize]*UiElement
+	inventoryDisplay [structs.MaxInventorySize]*ecs.BasicEntity
 
 	skillIcons   map[string]common.Drawable
 	skillFrames  [structs.SkillSlots]*common.SpaceComponent
 	skillDisplay [structs.SkillSlots]*ecs.BasicEntity
 
+	// A message for the player, like why something they tried didn't work, and how many more
+	// seconds it stays on screen for
+	message      string
+	messageTimer float32
+
 	input  *InputSystem
 	render *common.RenderSystem
 }
 
+// How long messages for the player stay on screen, in seconds
+const messageDuration = 4
+
 func (us *UiSystem) Update(dt float32) {
+	if us.messageTimer > 0 {
+		us.messageTimer -= dt
+	}
+
 	// Check for updates of dynamic text objects
 	for _, text := range us.dynamicTexts {
 		if text.UpdateFunc != nil {
@@ -65,17 +80,17 @@ func (us *UiSystem) Update(dt float32) {
 }
 
 func (us *UiSystem) Add(e *ecs.BasicEntity, text *DynamicText, space *common.SpaceComponent) {
-	us.dynamicTexts[e] = text
+	us.dynamicTexts[e.ID()] = text
 	us.render.Add(e, &text.RenderComponent, space)
 }
 
 func (us *UiSystem) Remove(entity ecs.BasicEntity) {
-	delete(us.dynamicTexts, &entity)
+	delete(us.dynamicTexts, entity.ID())
 }
 
 // New is the initialisation of the System
 func (us *UiSystem) New(w *ecs.World) {
-	us.dynamicTexts = make(map[*ecs.BasicEntity]*DynamicText)
+	us.dynamicTexts = make(map[uint64]*DynamicText)
 	us.actionIndicators = make(map[PlayerID][]*UiElement)
 
 	for _, system := range w.Systems() {
@@ -116,6 +131,14 @@ func (us *UiSystem) AddActionIndicator(action Event, playerID PlayerID, mapSyste
 			line.SpaceComponent = common.SpaceComponent{Position: engo.Point{start.X + structs.TileWidth/2 + offset, start.Y + structs.TileWidth/2 + offset}, Width: w, Height: h}
 			line.RenderComponent = common.RenderComponent{Drawable: common.Rectangle{}, Color: color.RGBA{0, 255, 0 + uint8(playerID*255), 255}}
 			lines = append(lines, &line)
+
+			// Warn about any hazards we know are on the path
+			if knowsHazard(mapSystem.GetTileAt(action.Path[i+1]), TeamPlayer) {
+				hazardCircle := &UiElement{BasicEntity: ecs.NewBasic()}
+				hazardCircle.SpaceComponent = common.SpaceComponent{Position: next, Width: structs.TileWidth, Height: structs.TileWidth}
+				hazardCircle.RenderComponent = common.RenderComponent{Drawable: common.Circle{BorderWidth: 3, BorderColor: color.RGBA{255, 140, 0, 255}}, Color: color.Transparent}
+				lines = append(lines, hazardCircle)
+			}
 		}
 		us.AddActionIndicators(playerID, lines)
 	case *UseSkill:
@@ -146,6 +169,37 @@ func (us *UiSystem) AddActionIndicator(action Event, playerID PlayerID, mapSyste
 		creatureCircle.SpaceComponent = common.SpaceComponent{Position: mapSystem.Creatures[action.CreatureId].Position, Width: structs.TileWidth, Height: structs.TileWidth}
 		creatureCircle.RenderComponent = common.RenderComponent{Drawable: common.Circle{BorderWidth: 3, BorderColor: color.RGBA{0, 255, 0, 255}}, Color: color.Transparent}
 		us.AddActionIndicators(playerID, []*UiElement{itemCircle, creatureCircle})
+	case *ToggleDoor:
+		doorCircle := &UiElement{BasicEntity: ecs.NewBasic()}
+		doorCircle.SpaceComponent = common.SpaceComponent{Position: action.Location.ToPixels(), Width: structs.TileWidth, Height: structs.TileWidth}
+		doorCircle.RenderComponent = common.RenderComponent{Drawable: common.Circle{BorderWidth: 3, BorderColor: color.RGBA{0, 255, 0, 255}}, Color: color.Transparent}
+		us.AddActionIndicators(playerID, []*UiElement{doorCircle})
+	case *UseItem:
+		loc := structs.PointToGridPoint(mapSystem.Creatures[action.CreatureId].Position)
+		if sourceLoc != nil {
+			loc = *sourceLoc
+		}
+		if item := mapSystem.Creatures[action.CreatureId].InventoryItem(action.InventorySlot); item != nil && item.Use.IsTargeted() {
+			loc = GetSkillTargetLocation(action.Target, mapSystem)
+		}
+		useCircle := &UiElement{BasicEntity: ecs.NewBasic()}
+		useCircle.SpaceComponent = common.SpaceComponent{Position: loc.ToPixels(), Width: structs.TileWidth, Height: structs.TileWidth}
+		useCircle.RenderComponent = common.RenderComponent{Drawable: common.Circle{BorderWidth: 3, BorderColor: color.RGBA{0, 140, 255, 255}}, Color: color.Transparent}
+		us.AddActionIndicators(playerID, []*UiElement{useCircle})
+	case *DropItem:
+		loc := structs.PointToGridPoint(mapSystem.Creatures[action.CreatureId].Position)
+		if sourceLoc != nil {
+			loc = *sourceLoc
+		}
+		dropCircle := &UiElement{BasicEntity: ecs.NewBasic()}
+		dropCircle.SpaceComponent = common.SpaceComponent{Position: loc.ToPixels(), Width: structs.TileWidth, Height: structs.TileWidth}
+		dropCircle.RenderComponent = common.RenderComponent{Drawable: common.Circle{BorderWidth: 3, BorderColor: color.RGBA{255, 255, 0, 255}}, Color: color.Transparent}
+		us.AddActionIndicators(playerID, []*UiElement{dropCircle})
+	case *PassItem:
+		allyCircle := &UiElement{BasicEntity: ecs.NewBasic()}
+		allyCircle.SpaceComponent = common.SpaceComponent{Position: mapSystem.Creatures[action.TargetId].Position, Width: structs.TileWidth, Height: structs.TileWidth}
+		allyCircle.RenderComponent = common.RenderComponent{Drawable: common.Circle{BorderWidth: 3, BorderColor: color.RGBA{255, 255, 0, 255}}, Color: color.Transparent}
+		us.AddActionIndicators(playerID, []*UiElement{allyCircle})
 	case *EquipItem, *UnequipItem:
 		us.AddActionIndicators(playerID, []*UiElement{})
 	}
@@ -188,22 +242,33 @@ func (us *UiSystem) UpdatePlayerDisplay() {
 		us.render.Add(&entity, &component, us.equipmentFrames[i])
 	}
 
-	inventory := us.input.player.Inventory
-	for i := 0; i < structs.InventorySize; i++ {
+	capacity := us.input.player.InventoryCapacity()
+	for i := 0; i < structs.MaxInventorySize; i++ {
 		if us.inventoryDisplay[i] != nil {
 			us.render.Remove(*us.inventoryDisplay[i])
 		}
-		if inventory[i] == nil {
+		item := us.input.player.InventoryItem(i)
+		us.inventoryFrames[i].Hidden = i >= capacity && item == nil
+		us.inventoryHotkeys[i].Hidden = us.inventoryFrames[i].Hidden
+
+		// Show how many are on a stack
+		count := ""
+		if item != nil && item.Count > 1 {
+			count = fmt.Sprintf("x%d", item.Count)
+		}
+		us.inventoryCounts[i].Drawable = common.Text{Font: us.inventoryCounts[i].Drawable.(common.Text).Font, Text: count}
+
+		if item == nil {
 			us.inventoryDisplay[i] = nil
 			continue
 		}
 		entity := ecs.NewBasic()
 		us.inventoryDisplay[i] = &entity
-		component := common.RenderComponent{Drawable: inventory[i].Drawable}
+		component := common.RenderComponent{Drawable: item.Drawable}
 		component.SetShader(common.HUDShader)
 		component.SetZIndex(3)
 		log.Infof("Adding inventory item display")
-		us.render.Add(&entity, &component, us.inventoryFrames[i])
+		us.render.Add(&entity, &component, &us.inventoryFrames[i].SpaceComponent)
 	}
 
 	skills := us.input.player.GetSkills()
@@ -238,6 +303,8 @@ func (us *UiSystem) InitUI(w *ecs.World, playerCount int) {
 	}
 
 	us.setupInventoryDisplay(font)
+	us.setupPileDisplay(font)
+	us.setupMessageDisplay(font)
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
 		case *TurnSystem:
@@ -265,21 +332,32 @@ func (us *UiSystem) setupInventoryDisplay(font *common.Font) {
 		us.render.Add(&hotkey.BasicEntity, &hotkey.RenderComponent, &hotkey.SpaceComponent)
 	}
 
-	for i := 0; i < structs.InventorySize; i++ {
-		itemFrame := UiElement{BasicEntity: ecs.NewBasic()}
+	for i := 0; i < structs.MaxInventorySize; i++ {
+		itemFrame := &UiElement{BasicEntity: ecs.NewBasic()}
 		itemFrame.SpaceComponent = common.SpaceComponent{Position: engo.Point{float32(24+64*i) + 4, 712 + 4}, Width: structs.TileWidth, Height: structs.TileWidth}
 		itemFrame.RenderComponent = common.RenderComponent{Drawable: common.Rectangle{BorderWidth: 2, BorderColor: color.White}, Color: color.RGBA{200, 153, 0, 125}}
 		itemFrame.SetShader(common.HUDShader)
 		itemFrame.RenderComponent.SetZIndex(2)
-		us.inventoryFrames[i] = &itemFrame.SpaceComponent
+		itemFrame.Hidden = i >= structs.InventorySize
+		us.inventoryFrames[i] = itemFrame
 		us.render.Add(&itemFrame.BasicEntity, &itemFrame.RenderComponent, &itemFrame.SpaceComponent)
 
-		hotkey := UiElement{BasicEntity: ecs.NewBasic()}
+		hotkey := &UiElement{BasicEntity: ecs.NewBasic()}
 		hotkey.SpaceComponent = common.SpaceComponent{Position: engo.Point{float32(24+64*i) + 4, 712 + 7}, Width: structs.TileWidth, Height: structs.TileWidth}
 		hotkey.RenderComponent = common.RenderComponent{Drawable: common.Text{Font: font, Text: string(InventoryHotkeys[i])}, Color: color.White}
 		hotkey.SetShader(common.HUDShader)
 		hotkey.RenderComponent.SetZIndex(4)
+		hotkey.Hidden = itemFrame.Hidden
+		us.inventoryHotkeys[i] = hotkey
 		us.render.Add(&hotkey.BasicEntity, &hotkey.RenderComponent, &hotkey.SpaceComponent)
+
+		count := &UiElement{BasicEntity: ecs.NewBasic()}
+		count.SpaceComponent = common.SpaceComponent{Position: engo.Point{float32(24+64*i) + 30, 712 + 44}, Width: structs.TileWidth, Height: structs.TileWidth}
+		count.RenderComponent = common.RenderComponent{Drawable: common.Text{Font: font}, Color: color.White}
+		count.SetShader(common.HUDShader)
+		count.RenderComponent.SetZIndex(4)
+		us.inventoryCounts[i] = count
+		us.render.Add(&count.BasicEntity, &count.RenderComponent, &count.SpaceComponent)
 	}
 
 	for i := 0; i < structs.SkillSlots; i++ {
@@ -300,6 +378,54 @@ func (us *UiSystem) setupInventoryDisplay(font *common.Font) {
 	}
 }
 
+// ShowMessage puts a message on screen for the player for a few seconds
+func (us *UiSystem) ShowMessage(message string) {
+	us.message = message
+	us.messageTimer = messageDuration
+}
+
+func (us *UiSystem) setupMessageDisplay(font *common.Font) {
+	messageText := DynamicText{BasicEntity: ecs.NewBasic()}
+	messageText.RenderComponent.Drawable = common.Text{
+		Font: font,
+	}
+	messageText.SetShader(common.HUDShader)
+	messageText.SpaceComponent.Position.Set(28, 604)
+	messageText.RenderComponent.Color = color.RGBA{255, 80, 80, 255}
+	messageText.RenderComponent.SetZIndex(2)
+	messageText.UpdateFunc = func() string {
+		if us.messageTimer <= 0 {
+			return ""
+		}
+		return us.message
+	}
+
+	us.Add(&messageText.BasicEntity, &messageText, &messageText.SpaceComponent)
+}
+
+// Shows which item gets picked up when the player clicks on the pile they're standing on
+func (us *UiSystem) setupPileDisplay(font *common.Font) {
+	pileText := DynamicText{BasicEntity: ecs.NewBasic()}
+	pileText.RenderComponent.Drawable = common.Text{
+		Font: font,
+	}
+	pileText.SetShader(common.HUDShader)
+	pileText.SpaceComponent.Position.Set(28, 626)
+	pileText.RenderComponent.SetZIndex(2)
+	pileText.UpdateFunc = func() string {
+		item, count := us.input.SelectedPileItem()
+		if item == nil {
+			return ""
+		}
+		if count == 1 {
+			return "Here: " + item.DisplayName()
+		}
+		return fmt.Sprintf("Here: %s (%d of %d, tab for next)", item.DisplayName(), us.input.pileIndex%count+1, count)
+	}
+
+	us.Add(&pileText.BasicEntity, &pileText, &pileText.SpaceComponent)
+}
+
 func (us *UiSystem) setupReadyIndicators(sys *TurnSystem, font *common.Font, playerCount int) {
 	for i := 0; i < playerCount; i++ {
 		readyStatus := DynamicText{BasicEntity: ecs.NewBasic()}
@@ -307,7 +433,7 @@ func (us *UiSystem) setupReadyIndicators(sys *TurnSystem, font *common.Font, pla
 			Font: font,
 		}
 		readyStatus.SetShader(common.HUDShader)
-		readyStatus.SpaceComponent.Position.Set(24, float32(120+(i*72)))
+		readyStatus.SpaceComponent.Position.Set(24, float32(144+(i*72)))
 		readyStatus.RenderComponent.SetZIndex(2)
 		playerNum := i + 1
 		readyStatus.UpdateFunc = func() string {
@@ -329,7 +455,7 @@ func (us *UiSystem) setupReadyIndicators(sys *TurnSystem, font *common.Font, pla
 				Font: font,
 			}
 			actionStatus.SetShader(common.HUDShader)
-			actionStatus.SpaceComponent.Position.Set(24, float32(138+(i*72)+(j*18)))
+			actionStatus.SpaceComponent.Position.Set(24, float32(162+(i*72)+(j*18)))
 			actionStatus.RenderComponent.SetZIndex(2)
 			actionNum := j
 			actionStatus.UpdateFunc = func() string {
@@ -380,7 +506,7 @@ func (us *UiSystem) SetupCreatureLifeDisplay(creature *structs.Creature) {
 func (us *UiSystem) SetupStatsDisplay(world *ecs.World) {
 	position := engo.Point{24, 24}
 	width := float32(320)
-	height := float32(80)
+	height := float32(104)
 	bgColor := color.RGBA{200, 153, 0, 125}
 
 	// Create the panel background
@@ -421,7 +547,7 @@ func (us *UiSystem) SetupStatsDisplay(world *ecs.World) {
 	lifeDisplay.RenderComponent.SetZIndex(2)
 
 	lifeDisplay.UpdateFunc = func() string {
-		return fmt.Sprintf("Life:    %d/%d", us.input.player.Life, us.input.player.GetEffectiveMaxLife())
+		return fmt.Sprintf("Life:    %d/%d", us.input.player.Life, us.input.player.Stats().MaxLife)
 	}
 	us.Add(&lifeDisplay.BasicEntity, &lifeDisplay, &lifeDisplay.SpaceComponent)
 
@@ -433,7 +559,8 @@ func (us *UiSystem) SetupStatsDisplay(world *ecs.World) {
 	staminaDisplay.SpaceComponent.Position.Set(position.X+10, position.Y+36)
 	staminaDisplay.RenderComponent.SetZIndex(2)
 	staminaDisplay.UpdateFunc = func() string {
-		return fmt.Sprintf("Stamina: %d/%d", us.input.player.Stamina, us.input.player.MaxStamina)
+		stats := us.input.player.Stats()
+		return fmt.Sprintf("Stamina: %d/%d (+%d)", us.input.player.Stamina, stats.MaxStamina, stats.StaminaRegen)
 	}
 	us.Add(&staminaDisplay.BasicEntity, &staminaDisplay, &staminaDisplay.SpaceComponent)
 
@@ -445,8 +572,25 @@ func (us *UiSystem) SetupStatsDisplay(world *ecs.World) {
 	statDisplay.SpaceComponent.Position.Set(position.X+10, position.Y+60)
 	statDisplay.RenderComponent.SetZIndex(2)
 	statDisplay.UpdateFunc = func() string {
-		return fmt.Sprintf("Str %d  Dex %d  Int %d", us.input.player.GetEffectiveStrength(),
-			us.input.player.GetEffectiveDexterity(), us.input.player.GetEffectiveIntelligence())
+		stats := us.input.player.Stats()
+		return fmt.Sprintf("Str %d Dex %d Int %d Mv %d", stats.Strength, stats.Dexterity, stats.Intelligence, stats.Movement)
 	}
 	us.Add(&statDisplay.BasicEntity, &statDisplay, &statDisplay.SpaceComponent)
+
+	levelDisplay := DynamicText{BasicEntity: ecs.NewBasic()}
+	levelDisplay.RenderComponent.Drawable = common.Text{
+		Font: fnt,
+	}
+	levelDisplay.SetShader(common.HUDShader)
+	levelDisplay.SpaceComponent.Position.Set(position.X+10, position.Y+84)
+	levelDisplay.RenderComponent.SetZIndex(2)
+	levelDisplay.UpdateFunc = func() string {
+		player := us.input.player
+		text := fmt.Sprintf("Lvl %d  XP %d/%d", player.Level, player.Experience, structs.XPForLevel(player.Level+1))
+		if player.StatPoints > 0 {
+			text += fmt.Sprintf(" +%d", player.StatPoints)
+		}
+		return text
+	}
+	us.Add(&levelDisplay.BasicEntity, &levelDisplay, &levelDisplay.SpaceComponent)
 }
@@ -2,12 +2,65 @@ package core
 
 import (
 	"engo.io/ecs"
+	"engo.io/engo"
 	"engo.io/engo/common"
+	"github.com/engoengine/math/imath"
+	"github.com/kyhavlov/go-dnd/mapgen"
 	"github.com/kyhavlov/go-dnd/structs"
 )
 
 // Utility functions for adding game objects to all the necessary world systems
 
+// LoadLevel sets up the map system for a new level and adds everything on it to the world
+func LoadLevel(w *ecs.World, level *mapgen.Map) {
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			sys.MapInfo = level
+			sys.Random = level.Random
+			sys.Tiles = make([][]*structs.Tile, level.Width)
+			for i, _ := range sys.Tiles {
+				sys.Tiles[i] = make([]*structs.Tile, level.Height)
+			}
+			sys.CreatureLocations = make([][]*structs.Creature, level.Width)
+			for i, _ := range sys.CreatureLocations {
+				sys.CreatureLocations[i] = make([]*structs.Creature, level.Height)
+			}
+			sys.ItemLocations = make([][][]*structs.Item, level.Width)
+			for i, _ := range sys.ItemLocations {
+				sys.ItemLocations[i] = make([][]*structs.Item, level.Height)
+				for j, _ := range sys.ItemLocations[i] {
+					sys.ItemLocations[i][j] = make([]*structs.Item, 0)
+				}
+			}
+		case *LightSystem:
+			for _, light := range level.Lights {
+				light.BasicEntity = ecs.NewBasic()
+				sys.Add(&light.BasicEntity, &BasicLightSource{
+					GridPoint:  light.GridPoint,
+					Brightness: uint8(imath.Min(light.Brightness, 255)),
+					Radius:     light.Radius,
+				})
+			}
+		}
+	}
+
+	common.CameraBounds.Max = engo.Point{
+		X: float32(level.Width * structs.TileWidth),
+		Y: float32(level.Height * structs.TileWidth),
+	}
+
+	for _, tile := range level.Tiles {
+		AddTile(w, tile)
+	}
+	for _, creature := range level.Creatures {
+		AddCreature(w, creature)
+	}
+	for _, item := range level.Items {
+		AddItem(w, item)
+	}
+}
+
 func AddCreature(w *ecs.World, creature *structs.Creature) {
 	for _, system := range w.Systems() {
 		switch sys := system.(type) {
@@ -24,6 +77,73 @@ func AddCreature(w *ecs.World, creature *structs.Creature) {
 			sys.AddCreature(creature)
 		case *UiSystem:
 			sys.SetupCreatureLifeDisplay(creature)
+		case *LightSystem:
+			sys.AddLight(&creature.BasicEntity, creature.Light, &creature.SpaceComponent)
+		}
+	}
+
+	// Anything the creature's already carrying, like a saved character's gear, gets added too
+	for _, item := range creature.Equipment {
+		if item != nil {
+			addCarriedItem(w, creature, item, true)
+		}
+	}
+	for _, item := range creature.Inventory {
+		if item != nil {
+			addCarriedItem(w, creature, item, false)
+		}
+	}
+
+	for _, name := range creature.StartingItems {
+		GiveItem(w, creature, structs.NewItem(name, structs.PointToGridPoint(creature.Position)))
+	}
+}
+
+// GiveItem puts a new item straight into a creature's hands, equipping it if the slot is free
+// and putting it in the inventory otherwise. If there's no room for it, it's dropped at the
+// creature's feet instead.
+func GiveItem(w *ecs.World, creature *structs.Creature, item *structs.Item) {
+	equip := creature.CanEquipItem(item) && creature.Equipment[item.Type] == nil
+	if stack := creature.StackSlot(item); !equip && stack != -1 {
+		creature.Inventory[stack].Count += item.Count
+		return
+	}
+	slot := creature.FreeInventorySlot()
+	if !equip && slot == -1 {
+		AddItem(w, item)
+		return
+	}
+
+	item.OnGround = false
+	item.RenderComponent.Hidden = true
+	if equip {
+		creature.EquipNew(item)
+	} else {
+		creature.SetInventoryItem(slot, item)
+	}
+	addCarriedItem(w, creature, item, equip)
+}
+
+// Adds an item the creature is carrying to the world, with its light following the creature
+// if it's equipped
+func addCarriedItem(w *ecs.World, creature *structs.Creature, item *structs.Item, equipped bool) {
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *NetworkSystem:
+			item.NetworkID = sys.nextId()
+		}
+	}
+
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *common.RenderSystem:
+			sys.Add(&item.BasicEntity, &item.RenderComponent, &item.SpaceComponent)
+		case *MapSystem:
+			sys.AddCarriedItem(item)
+		case *LightSystem:
+			if equipped {
+				sys.AddLight(&item.BasicEntity, item.Light, &creature.SpaceComponent)
+			}
 		}
 	}
 }
@@ -42,6 +162,38 @@ func AddItem(w *ecs.World, item *structs.Item) {
 			sys.Add(&item.BasicEntity, &item.RenderComponent, &item.SpaceComponent)
 		case *MapSystem:
 			sys.AddItem(item)
+		case *LightSystem:
+			if item.OnGround {
+				sys.AddLight(&item.BasicEntity, item.Light, &item.SpaceComponent)
+			}
+		}
+	}
+}
+
+// ReplaceTile swaps out the tile at the new tile's location, such as when a door is opened
+func ReplaceTile(w *ecs.World, tile *structs.Tile) {
+	var old *structs.Tile
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *MapSystem:
+			old = sys.GetTileAt(tile.GridPoint)
+			sys.Tiles[tile.X][tile.Y] = tile
+		}
+	}
+
+	for _, system := range w.Systems() {
+		switch sys := system.(type) {
+		case *common.RenderSystem:
+			if old != nil {
+				sys.Remove(old.BasicEntity)
+			}
+			sys.Add(&tile.BasicEntity, &tile.RenderComponent, &tile.SpaceComponent)
+		case *LightSystem:
+			if old != nil {
+				sys.Remove(old.BasicEntity)
+			}
+			sys.AddLight(&tile.BasicEntity, tile.Light, &tile.SpaceComponent)
+			sys.needsUpdate = true
 		}
 	}
 }
@@ -68,6 +220,10 @@ func AddTile(w *ecs.World, tile *structs.Tile) {
 			if added {
 				sys.Add(&tile.BasicEntity, &tile.RenderComponent, &tile.SpaceComponent)
 			}
+		case *LightSystem:
+			if added {
+				sys.AddLight(&tile.BasicEntity, tile.Light, &tile.SpaceComponent)
+			}
 		}
 	}
 }
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"engo.io/ecs"
+	"engo.io/engo"
+	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/mapgen"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+func TestMain(m *testing.M) {
+	// Render components send a message when they're set up, which needs a mailbox
+	// to go to even without a game window
+	engo.Mailbox = &engo.MessageManager{}
+
+	if err := structs.LoadDataFile("../data.hcl"); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// The tiles each character stands for in the rows of a test map
+var testTiles = map[rune]string{
+	'.': "Dungeon Floor",
+	'#': "Dungeon Wall",
+	'+': "Door",
+	'>': "Stairs Down",
+	'^': "Spike Trap",
+	'~': "Fire",
+}
+
+// Makes a world with the game logic systems, on a map made from the given rows of tiles
+// (see testTiles)
+func newTestWorld(rows ...string) (*ecs.World, *MapSystem) {
+	w := &ecs.World{}
+	mapSystem := &MapSystem{}
+	w.AddSystem(&NetworkSystem{})
+	w.AddSystem(mapSystem)
+	w.AddSystem(&LightSystem{})
+
+	level := &mapgen.Map{Width: len(rows[0]), Height: len(rows), Seed: 1, Floor: 1}
+	level.Random = rand.New(rand.NewSource(level.Seed))
+	for y, row := range rows {
+		for x, char := range row {
+			level.Tiles = append(level.Tiles, structs.NewTile(testTiles[char], structs.GridPoint{X: x, Y: y}, level.Random))
+		}
+	}
+	LoadLevel(w, level)
+	return w, mapSystem
+}
+
+// Adds a player of the given class to the world as the given player ID. The class's starting
+// items are left out so tests can hand out exactly the items they need.
+func addTestPlayer(w *ecs.World, ms *MapSystem, id PlayerID, class string, loc structs.GridPoint) *structs.Creature {
+	player := structs.NewPlayer(class, loc)
+	player.StartingItems = nil
+	AddCreature(w, player)
+	ms.Players[id] = player
+	return player
+}
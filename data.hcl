@@ -1,3 +1,7 @@
+// Bump this when removing or changing items, affixes or classes in a way that could break
+// saved characters, so older saves get upgraded when they're loaded
+version = 1
+
 // Items
 item "Sapphire Staff" {
   slot = "weapon"
@@ -18,6 +22,15 @@ item "Leather Armor" {
   }
 }
 
+item "Torch" {
+  slot = "off-hand"
+  icon = 1650
+  light {
+    brightness = 220
+    radius = 6
+  }
+}
+
 item "Ice Spear" {
   slot = "weapon"
   icon = 1836
@@ -29,9 +42,182 @@ item "Ice Spear" {
   }
 }
 
-// Creatures
+item "Traveler's Pack" {
+  slot = "accessory"
+  icon = 1720
+  inventory_slots = 2
+}
+
+// Consumables, used up once they run out of charges. Copies of the same consumable stack
+// up to stack_size in one inventory slot
+item "Healing Potion" {
+  slot = "consumable"
+  icon = 1880
+  stack_size = 5
+  use {
+    heal = 20
+  }
+}
+
+item "Stamina Potion" {
+  slot = "consumable"
+  icon = 1881
+  stack_size = 5
+  use {
+    stamina = 30
+  }
+}
+
+item "Potion of Haste" {
+  slot = "consumable"
+  icon = 1882
+  use {
+    status {
+      name = "Haste"
+      turns = 3
+      bonus {
+        move = 3
+      }
+    }
+  }
+}
+
+item "Scroll of Blinking" {
+  slot = "consumable"
+  icon = 1900
+  use {
+    teleport = true
+    range = 6
+  }
+}
+
+item "Fire Bomb" {
+  slot = "consumable"
+  icon = 1890
+  charges = 2
+  stack_size = 3
+  use {
+    skill = "Fire Bomb"
+  }
+}
+
+// Item rarities, which decide how many affixes get rolled onto items found in the dungeon
+rarity "Common" {
+  weight = 60
+}
+
+rarity "Magic" {
+  weight = 28
+  prefixes = 1
+}
+
+rarity "Rare" {
+  weight = 10
+  prefixes = 1
+  suffixes = 1
+}
+
+rarity "Unique" {
+  weight = 2
+  prefixes = 2
+  suffixes = 2
+}
+
+// Item affixes. Like items, they can have flat bonuses, and percent_bonus blocks that raise
+// a stat by a percentage after all the flat bonuses are added up
+affix "Sturdy" {
+  kind = "prefix"
+  weight = 3
+  slots = ["armor", "helm", "off-hand"]
+  bonus {
+    life = 10
+  }
+}
+
+affix "Heavy" {
+  kind = "prefix"
+  weight = 2
+  slots = ["weapon"]
+  reqs {
+    str = 14
+  }
+  bonus {
+    str = 6
+  }
+}
+
+affix "Gleaming" {
+  kind = "prefix"
+  weight = 2
+  bonus {
+    int = 4
+    stamina = 10
+  }
+}
+
+affix "Nimble" {
+  kind = "prefix"
+  weight = 2
+  bonus {
+    dex = 4
+    move = 1
+  }
+}
+
+affix "of the Bear" {
+  kind = "suffix"
+  weight = 3
+  reqs {
+    str = 12
+  }
+  bonus {
+    str = 4
+    life = 5
+  }
+}
+
+affix "Towering" {
+  kind = "prefix"
+  weight = 1
+  slots = ["armor", "helm"]
+  percent_bonus {
+    life = 15
+  }
+}
+
+affix "of Vigor" {
+  kind = "suffix"
+  weight = 3
+  bonus {
+    stamina_regen = 2
+  }
+}
+
+affix "of Flames" {
+  kind = "suffix"
+  weight = 1
+  slots = ["weapon"]
+  skills = ["Fireball"]
+  reqs {
+    int = 14
+  }
+}
+
+affix "of Mending" {
+  kind = "suffix"
+  weight = 1
+  slots = ["weapon", "off-hand"]
+  skills = ["Mend"]
+}
+
+// Creatures. Killing a creature splits its xp between the living players
 creature "Player" {
   icon = 594
+  inventory_slots = 5
+
+  light {
+    brightness = 250
+  }
 
   stats {
     move = 8
@@ -42,10 +228,114 @@ creature "Player" {
     stamina = 50
     stamina_regen = 3
   }
+
+  // Gained at every level, on top of the stat points to spend
+  level_up {
+    life = 5
+    stamina = 5
+  }
+}
+
+// Player classes, picked before the game starts. A class replaces the Player creature's stats,
+// icon and level_up, and adds its items and skills to it. Unlocks are skills learned on
+// reaching a level
+class "Warrior" {
+  icon = 594
+  items = ["Leather Armor", "Torch", "Healing Potion"]
+
+  stats {
+    move = 8
+    life = 50
+    str = 16
+    dex = 12
+    int = 10
+    stamina = 50
+    stamina_regen = 4
+  }
+
+  level_up {
+    life = 7
+    stamina = 4
+  }
+
+  unlock "Cleave" { level = 2 }
+}
+
+class "Mage" {
+  icon = 595
+  items = ["Sapphire Staff", "Torch"]
+  skills = ["Fireball"]
+
+  stats {
+    move = 7
+    life = 32
+    str = 9
+    dex = 12
+    int = 17
+    stamina = 60
+    stamina_regen = 4
+  }
+
+  level_up {
+    life = 3
+    stamina = 8
+  }
+
+  unlock "Ice Storm" { level = 3 }
+  unlock "Frozen Lance" { level = 5 }
+}
+
+class "Rogue" {
+  icon = 596
+  items = ["Leather Armor", "Fire Bomb", "Scroll of Blinking"]
+  skills = ["Bone Arrow"]
+
+  stats {
+    move = 9
+    life = 38
+    str = 11
+    dex = 17
+    int = 11
+    stamina = 50
+    stamina_regen = 3
+  }
+
+  level_up {
+    life = 5
+    stamina = 5
+  }
+
+  unlock "Throw Bomb" { level = 3 }
+}
+
+class "Cleric" {
+  icon = 597
+  items = ["Torch", "Healing Potion"]
+  skills = ["Mend"]
+
+  stats {
+    move = 7
+    life = 42
+    str = 12
+    dex = 10
+    int = 15
+    stamina = 55
+    stamina_regen = 4
+  }
+
+  level_up {
+    life = 5
+    stamina = 6
+  }
+
+  unlock "Fireball" { level = 4 }
 }
 
 creature "Skeleton" {
   icon = 533
+  xp = 20
+  behavior = "brute"
+  loot_table = "Bones"
 
   stats {
     move = 5
@@ -58,9 +348,294 @@ creature "Skeleton" {
   }
 }
 
+creature "Skeleton Archer" {
+  icon = 534
+  xp = 25
+  behavior = "ranged"
+  skills = ["Bone Arrow"]
+  loot_table = "Bones"
+
+  stats {
+    move = 5
+    life = 15
+    str = 10
+    dex = 14
+    int = 10
+    stamina = 30
+    stamina_regen = 3
+  }
+}
+
+creature "Skeleton Mage" {
+  icon = 535
+  xp = 30
+  behavior = "caster"
+  skills = ["Fireball", "Ice Storm"]
+  loot_table = "Magic"
+
+  stats {
+    move = 4
+    life = 15
+    str = 8
+    dex = 10
+    int = 16
+    stamina = 40
+    stamina_regen = 4
+  }
+}
+
+creature "Skeleton Priest" {
+  icon = 536
+  xp = 30
+  behavior = "support"
+  skills = ["Mend", "Bone Arrow"]
+  loot_table = "Magic"
+
+  stats {
+    move = 4
+    life = 18
+    str = 8
+    dex = 12
+    int = 14
+    stamina = 40
+    stamina_regen = 4
+  }
+}
+
+creature "Kobold" {
+  icon = 540
+  xp = 15
+  behavior = "coward"
+  sight = 12
+  inventory_slots = 2
+  items = ["Torch", "Fire Bomb"]
+  loot_table = "Scraps"
+
+  stats {
+    move = 6
+    life = 14
+    str = 10
+    dex = 14
+    int = 8
+    stamina = 30
+    stamina_regen = 3
+  }
+}
+
+// Bosses
+creature "Skeleton King" {
+  icon = 560
+  xp = 250
+  boss = true
+  behavior = "brute"
+  skills = ["Cleave"]
+  loot_table = "Royal Hoard"
+
+  stats {
+    move = 5
+    life = 80
+    str = 18
+    dex = 12
+    int = 12
+    stamina = 60
+    stamina_regen = 5
+  }
+
+  phase "Call the Guard" {
+    life_percent = 60
+    summons = ["Skeleton", "Skeleton Archer"]
+  }
+
+  phase "Bone Storm" {
+    life_percent = 30
+    behavior = "caster"
+    skills = ["Ice Storm"]
+    summons = ["Skeleton Priest"]
+    bonus {
+      int = 8
+      stamina_regen = 3
+    }
+  }
+}
+
+// Loot tables, rolled for extra drops when a creature dies
+loot_table "Bones" {
+  chance = 25
+  item "Torch" { weight = 2 }
+  item "Leather Armor" { weight = 1 }
+  item "Healing Potion" { weight = 2 }
+}
+
+loot_table "Magic" {
+  chance = 40
+  item "Sapphire Staff" { weight = 1 }
+  item "Ice Spear" { weight = 1 }
+  item "Stamina Potion" { weight = 1 }
+  item "Scroll of Blinking" { weight = 1 }
+}
+
+loot_table "Scraps" {
+  chance = 50
+  item "Leather Armor" { weight = 1 }
+  item "Fire Bomb" { weight = 1 }
+  item "Traveler's Pack" { weight = 1 }
+}
+
+loot_table "Royal Hoard" {
+  always = ["Sapphire Staff", "Ice Spear"]
+  chance = 50
+  item "Leather Armor" { weight = 1 }
+}
+
 // Tiles
 tile "Dungeon Floor" {
   icons = [861, 862, 863, 864, 865, 866, 867, 868]
+  walkable = true
+}
+
+tile "Dungeon Wall" {
+  icons = [856, 857, 858]
+  blocks_sight = true
+  blocks_light = true
+}
+
+tile "Door" {
+  icons = [871]
+  blocks_sight = true
+  blocks_light = true
+  toggle = "Open Door"
+}
+
+tile "Open Door" {
+  icons = [872]
+  walkable = true
+  toggle = "Door"
+}
+
+tile "Stairs Down" {
+  icons = [870]
+  walkable = true
+  stairs = true
+}
+
+tile "Wall Torch" {
+  icons = [846]
+  walkable = true
+  light {
+    brightness = 230
+    radius = 5
+  }
+}
+
+// Hazards
+tile "Spike Trap" {
+  icons = [881]
+  walkable = true
+  hazard {
+    step_damage = 8
+    stops_movement = true
+    hidden = true
+    disguise = "Dungeon Floor"
+    detect_range = 2
+  }
+}
+
+tile "Fire" {
+  icons = [882, 883]
+  walkable = true
+  movement_cost = 2
+  light {
+    brightness = 200
+    radius = 3
+  }
+  hazard {
+    step_damage = 4
+    turn_end_damage = 6
+  }
+}
+
+tile "Poison Gas" {
+  icons = [884]
+  walkable = true
+  hazard {
+    turn_end_damage = 3
+    stamina_drain = 10
+  }
+}
+
+tile "Pit" {
+  icons = [885]
+  walkable = true
+  movement_cost = 3
+  hazard {
+    step_damage = 10
+    stops_movement = true
+  }
+}
+
+// Room themes, which decide what gets spawned in each room of a floor
+theme "Empty" {
+  weight = 5
+}
+
+theme "Guard Room" {
+  weight = 6
+  creatures = 1
+  extra_creatures = 3
+
+  creature "Skeleton" { weight = 3 }
+  creature "Skeleton Archer" { weight = 1 }
+  creature "Skeleton Mage" { weight = 1 }
+  creature "Skeleton Priest" { weight = 1 }
+  creature "Kobold" { weight = 1 }
+}
+
+theme "Treasure Vault" {
+  weight = 2
+  creatures = 1
+  extra_creatures = 1
+  items = 1
+  extra_items = 2
+
+  creature "Skeleton" { weight = 2 }
+  creature "Skeleton Archer" { weight = 1 }
+
+  item "Torch" { weight = 3 }
+  item "Leather Armor" { weight = 3 }
+  item "Ice Spear" { weight = 1 }
+  item "Sapphire Staff" { weight = 1 }
+  item "Healing Potion" { weight = 2 }
+  item "Potion of Haste" { weight = 1 }
+  item "Traveler's Pack" { weight = 1 }
+}
+
+theme "Shrine" {
+  weight = 2
+  creatures = 1
+  items = 1
+
+  creature "Skeleton Priest" { weight = 1 }
+
+  item "Sapphire Staff" { weight = 1 }
+
+  light {
+    brightness = 240
+    radius = 4
+  }
+}
+
+theme "Trap Corridor" {
+  weight = 2
+  extra_creatures = 2
+  hazards = 3
+  extra_hazards = 3
+
+  creature "Kobold" { weight = 1 }
+
+  hazard "Spike Trap" { weight = 4 }
+  hazard "Fire" { weight = 2 }
+  hazard "Poison Gas" { weight = 2 }
+  hazard "Pit" { weight = 1 }
 }
 
 // Skills
@@ -72,6 +647,7 @@ skill "Basic Attack" {
 
   damage = 0
   stamina_cost = 5
+  noise = 4
 
   damage_bonuses {
     str = 0.1
@@ -88,10 +664,59 @@ skill "Fireball" {
 
   damage = 10
   stamina_cost = 10
+  noise = 8
 
   damage_bonuses {
     int = 0.5
   }
+
+  light {
+    brightness = 240
+    radius = 3
+  }
+}
+
+skill "Fire Bomb" {
+  icon = 2761
+
+  min_range = 1
+  max_range = 4
+  targets_ground = true
+
+  damage = 12
+  stamina_cost = 0
+  noise = 8
+
+  effects {
+    aoe_radius = 1
+  }
+
+  light {
+    brightness = 240
+    radius = 3
+  }
+}
+
+// The Rogue's own bombs, weaker than the ones found in the dungeon but paid for with stamina
+skill "Throw Bomb" {
+  icon = 2761
+
+  min_range = 1
+  max_range = 4
+  targets_ground = true
+
+  damage = 9
+  stamina_cost = 14
+  noise = 8
+
+  effects {
+    aoe_radius = 1
+  }
+
+  light {
+    brightness = 200
+    radius = 2
+  }
 }
 
 skill "Cleave" {
@@ -134,6 +759,36 @@ skill "Ice Storm" {
   }
 }
 
+skill "Bone Arrow" {
+  icon = 2790
+
+  min_range = 2
+  max_range = 5
+
+  damage = 4
+  stamina_cost = 8
+
+  damage_bonuses {
+    dex = 0.2
+  }
+}
+
+skill "Mend" {
+  icon = 2800
+
+  min_range = 0
+  max_range = 4
+
+  damage = 6
+  stamina_cost = 12
+
+  damage_bonuses {
+    int = 0.3
+  }
+
+  tags = ["heal"]
+}
+
 skill "Frozen Lance" {
   icon = 2781
 
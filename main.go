@@ -1,14 +1,32 @@
 package main
 
 import (
+	"flag"
+	"strings"
+
 	"engo.io/engo"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/kyhavlov/go-dnd/core"
+	"github.com/kyhavlov/go-dnd/mapgen"
+	"github.com/kyhavlov/go-dnd/structs"
 	prefixed "github.com/x-cray/logrus-prefixed-formatter"
 )
 
 func main() {
+	generator := flag.String("generator", mapgen.DefaultGenerator, "the map generator to use when hosting: "+strings.Join(mapgen.GetGeneratorNames(), ", "))
+	params := mapgen.DefaultParams()
+	params.AddFlags(flag.CommandLine)
+	class := flag.String("class", "Warrior", "the class to play as: Warrior, Mage, Rogue or Cleric")
+	name := flag.String("name", "", "the name to save your character under, and load it from if it's been saved")
+	resume := flag.String("resume", "", "a saved game to resume when hosting, like "+core.GameSavePath)
+	flag.Parse()
+	if *name != "" {
+		if err := structs.ValidCharacterName(*name); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// Set up logging
 	formatter := new(prefixed.TextFormatter)
 	formatter.ForceColors = true
@@ -25,7 +43,7 @@ func main() {
 	// Register the types of network message that will be sent
 	core.RegisterEvents()
 
-	scene := &core.DungeonScene{}
+	scene := &core.DungeonScene{Class: *class, Name: *name, Resume: *resume, Generator: *generator, Params: params}
 	scene.Start()
 
 	engo.Run(opts, scene)
@@ -0,0 +1,143 @@
+package mapgen
+
+import (
+	"math/rand"
+
+	"github.com/engoengine/math/imath"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// BSPGenerator splits the level area up into smaller and smaller pieces (binary space
+// partitioning), puts a room in each piece, then joins the two halves of every split
+// together with a hallway.
+type BSPGenerator struct {
+	Params Params
+}
+
+// A rectangle of the level area in the partition tree
+type bspNode struct {
+	structs.GridPoint
+	Width  int
+	Height int
+
+	left, right *bspNode
+	room        *RoomNode
+}
+
+func (g *BSPGenerator) Generate(random *rand.Rand, floor int) *Map {
+	root := &bspNode{
+		Width:  g.Params.Width,
+		Height: g.Params.Height,
+	}
+
+	var rooms Rooms
+	var hallways []structs.GridPoint
+	g.split(random, root)
+	g.placeRooms(random, root, &rooms)
+	connectBSP(random, root, &hallways)
+
+	// Start in the first room and measure depth from there
+	startingRoom := rooms[0]
+	setDepths(startingRoom, rooms)
+
+	return buildLevel(random, rooms, startingRoom, hallways, floor)
+}
+
+// Recursively splits the node until the pieces are too small to hold two rooms
+func (g *BSPGenerator) split(random *rand.Rand, node *bspNode) {
+	// Leave a tile on each side of a room so they never touch
+	minSize := g.Params.MinRoomSize + 2
+
+	canSplitX := node.Width >= minSize*2
+	canSplitY := node.Height >= minSize*2
+	if !canSplitX && !canSplitY {
+		return
+	}
+
+	// Prefer splitting along the longer side to keep the pieces roughly square
+	splitX := canSplitX && (!canSplitY || node.Width > node.Height || (node.Width == node.Height && random.Intn(2) == 0))
+	if splitX {
+		at := minSize + random.Intn(node.Width-minSize*2+1)
+		node.left = &bspNode{GridPoint: node.GridPoint, Width: at, Height: node.Height}
+		node.right = &bspNode{
+			GridPoint: structs.GridPoint{X: node.X + at, Y: node.Y},
+			Width:     node.Width - at,
+			Height:    node.Height,
+		}
+	} else {
+		at := minSize + random.Intn(node.Height-minSize*2+1)
+		node.left = &bspNode{GridPoint: node.GridPoint, Width: node.Width, Height: at}
+		node.right = &bspNode{
+			GridPoint: structs.GridPoint{X: node.X, Y: node.Y + at},
+			Width:     node.Width,
+			Height:    node.Height - at,
+		}
+	}
+
+	g.split(random, node.left)
+	g.split(random, node.right)
+}
+
+// Puts a randomly sized room somewhere inside each leaf of the tree
+func (g *BSPGenerator) placeRooms(random *rand.Rand, node *bspNode, rooms *Rooms) {
+	if node.left != nil {
+		g.placeRooms(random, node.left, rooms)
+		g.placeRooms(random, node.right, rooms)
+		return
+	}
+
+	// Keep a tile free on each side, but always make some kind of room
+	width := imath.Max(imath.Min(g.Params.roomSize(random), node.Width-2), 1)
+	height := imath.Max(imath.Min(g.Params.roomSize(random), node.Height-2), 1)
+
+	node.room = &RoomNode{
+		Neighbors: make(map[int]*RoomNode),
+		Id:        len(*rooms),
+		GridPoint: structs.GridPoint{
+			X: node.X + 1 + randomUpTo(random, node.Width-width-1),
+			Y: node.Y + 1 + randomUpTo(random, node.Height-height-1),
+		},
+		Width:  width,
+		Height: height,
+	}
+	*rooms = append(*rooms, node.room)
+}
+
+// Joins a random room from each half of every split with a hallway. Returns a room
+// from under the node for its parent to connect to.
+func connectBSP(random *rand.Rand, node *bspNode, hallways *[]structs.GridPoint) *RoomNode {
+	if node.left == nil {
+		return node.room
+	}
+
+	a := connectBSP(random, node.left, hallways)
+	b := connectBSP(random, node.right, hallways)
+
+	a.Neighbors[b.Id] = b
+	b.Neighbors[a.Id] = a
+	*hallways = append(*hallways, lHallway(a.RandomPoint(random), b.RandomPoint(random))...)
+
+	if random.Intn(2) == 0 {
+		return a
+	}
+	return b
+}
+
+// Returns an L-shaped hallway from a to b, going across and then up or down
+func lHallway(a, b structs.GridPoint) []structs.GridPoint {
+	var hallway []structs.GridPoint
+	stepX, stepY := 1, 1
+	if b.X < a.X {
+		stepX = -1
+	}
+	if b.Y < a.Y {
+		stepY = -1
+	}
+	for x := a.X; x != b.X; x += stepX {
+		hallway = append(hallway, structs.GridPoint{X: x, Y: a.Y})
+	}
+	for y := a.Y; y != b.Y; y += stepY {
+		hallway = append(hallway, structs.GridPoint{X: b.X, Y: y})
+	}
+	return append(hallway, b)
+}
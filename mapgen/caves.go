@@ -0,0 +1,195 @@
+package mapgen
+
+import (
+	"math/rand"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// CaveGenerator makes winding caves with a cellular automaton: the area starts as random
+// rock and open space, then gets smoothed out by making each cell match most of its
+// neighbors. Chambers are carved out of the cave to hold the start, enemies and boss.
+type CaveGenerator struct {
+	Params Params
+}
+
+// How many times to try making a cave before falling back to the rooms generator
+const maxCaveAttempts = 10
+
+func (g *CaveGenerator) Generate(random *rand.Rand, floor int) *Map {
+	var cave []structs.GridPoint
+	var rooms Rooms
+	for attempt := 0; attempt < maxCaveAttempts; attempt++ {
+		cave = g.largestRegion(g.smooth(g.fill(random)))
+		rooms = g.carveChambers(random, cave)
+		if len(rooms) >= 2 {
+			break
+		}
+		log.Debugf("Cave only had room for %d chambers, trying again", len(rooms))
+	}
+	if len(rooms) < 2 {
+		log.Warnf("Couldn't generate a usable cave, falling back to rooms")
+		return (&RoomsGenerator{g.Params}).Generate(random, floor)
+	}
+
+	// Order the chambers by how far they are through the cave from the start
+	startingRoom := rooms[0]
+	distances := caveDistances(startingRoom.GridPoint, cave, rooms)
+	for _, room := range rooms {
+		room.depth = distances[room.GridPoint]
+	}
+	sort.Stable(rooms)
+
+	return buildLevel(random, rooms, startingRoom, cave, floor)
+}
+
+// Randomly fills the area with rock, leaving a solid border around the edge
+func (g *CaveGenerator) fill(random *rand.Rand) [][]bool {
+	rock := make([][]bool, g.Params.Width)
+	for x := range rock {
+		rock[x] = make([]bool, g.Params.Height)
+		for y := range rock[x] {
+			edge := x == 0 || y == 0 || x == g.Params.Width-1 || y == g.Params.Height-1
+			rock[x][y] = edge || random.Intn(100) < g.Params.RockPercent
+		}
+	}
+	return rock
+}
+
+// Turns cells with mostly rock around them into rock and the rest into open space
+func (g *CaveGenerator) smooth(rock [][]bool) [][]bool {
+	for step := 0; step < g.Params.SmoothSteps; step++ {
+		next := make([][]bool, len(rock))
+		for x := range rock {
+			next[x] = make([]bool, len(rock[x]))
+			for y := range rock[x] {
+				walls := 0
+				for i := -1; i <= 1; i++ {
+					for j := -1; j <= 1; j++ {
+						nx, ny := x+i, y+j
+						if (i != 0 || j != 0) && (nx < 0 || ny < 0 || nx >= len(rock) || ny >= len(rock[x]) || rock[nx][ny]) {
+							walls++
+						}
+					}
+				}
+				switch {
+				case walls > 4:
+					next[x][y] = true
+				case walls < 4:
+					next[x][y] = false
+				default:
+					next[x][y] = rock[x][y]
+				}
+			}
+		}
+		rock = next
+	}
+	return rock
+}
+
+// Returns the open points in the biggest connected area of the cave, so nothing
+// gets placed somewhere the players can't reach
+func (g *CaveGenerator) largestRegion(rock [][]bool) []structs.GridPoint {
+	seen := make(map[structs.GridPoint]bool)
+	var largest []structs.GridPoint
+	for x := range rock {
+		for y := range rock[x] {
+			start := structs.GridPoint{X: x, Y: y}
+			if rock[x][y] || seen[start] {
+				continue
+			}
+
+			var region []structs.GridPoint
+			queue := []structs.GridPoint{start}
+			seen[start] = true
+			for len(queue) > 0 {
+				current := queue[0]
+				queue = queue[1:]
+				region = append(region, current)
+				for _, next := range neighbors(current) {
+					if next.X < 0 || next.Y < 0 || next.X >= len(rock) || next.Y >= len(rock[0]) {
+						continue
+					}
+					if !rock[next.X][next.Y] && !seen[next] {
+						seen[next] = true
+						queue = append(queue, next)
+					}
+				}
+			}
+
+			if len(region) > len(largest) {
+				largest = region
+			}
+		}
+	}
+	return largest
+}
+
+// Carves rectangular chambers centered on random points of the cave
+func (g *CaveGenerator) carveChambers(random *rand.Rand, cave []structs.GridPoint) Rooms {
+	var rooms Rooms
+	if len(cave) == 0 {
+		return rooms
+	}
+
+	count := g.Params.length(random)
+	for attempt := 0; attempt < count*10 && len(rooms) < count; attempt++ {
+		center := cave[random.Intn(len(cave))]
+		room := &RoomNode{
+			Neighbors: make(map[int]*RoomNode),
+			Id:        len(rooms),
+			Width:     g.Params.MinRoomSize,
+			Height:    g.Params.MinRoomSize,
+		}
+		room.X = center.X - room.Width/2
+		room.Y = center.Y - room.Height/2
+		if room.X < 1 || room.Y < 1 || room.X+room.Width >= g.Params.Width || room.Y+room.Height >= g.Params.Height {
+			continue
+		}
+		if roomIsValid(room, rooms) {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms
+}
+
+// Finds how many steps through the cave it is from the start to each room
+func caveDistances(start structs.GridPoint, cave []structs.GridPoint, rooms Rooms) map[structs.GridPoint]int {
+	open := make(map[structs.GridPoint]bool)
+	for _, point := range cave {
+		open[point] = true
+	}
+	for _, room := range rooms {
+		for i := 0; i < room.Width; i++ {
+			for j := 0; j < room.Height; j++ {
+				open[structs.GridPoint{X: room.X + i, Y: room.Y + j}] = true
+			}
+		}
+	}
+
+	distances := map[structs.GridPoint]int{start: 0}
+	queue := []structs.GridPoint{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range neighbors(current) {
+			if _, ok := distances[next]; open[next] && !ok {
+				distances[next] = distances[current] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return distances
+}
+
+// Returns the points next to the given one, not counting diagonals
+func neighbors(point structs.GridPoint) []structs.GridPoint {
+	return []structs.GridPoint{
+		{X: point.X + 1, Y: point.Y},
+		{X: point.X - 1, Y: point.Y},
+		{X: point.X, Y: point.Y + 1},
+		{X: point.X, Y: point.Y - 1},
+	}
+}
@@ -0,0 +1,159 @@
+package mapgen
+
+import (
+	"flag"
+	"math/rand"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/engoengine/math/imath"
+)
+
+// Generator is an algorithm for laying out a floor of the dungeon. Generators are given
+// an already-seeded random source so every client makes the same map.
+type Generator interface {
+	Generate(random *rand.Rand, floor int) *Map
+}
+
+// Params tweak how a generator lays out a floor. Start from DefaultParams and change the
+// fields you want, since zero is a real setting for some of them. Generators ignore the
+// fields that don't apply to them.
+type Params struct {
+	// How many rooms deep the dungeon is, plus a random amount up to ExtraLength
+	Length      int
+	ExtraLength int
+
+	// The smallest rooms can be, plus a random amount up to RoomSizeRange
+	MinRoomSize   int
+	RoomSizeRange int
+
+	// The size of the area that BSP and cave levels are carved out of
+	Width  int
+	Height int
+
+	// The percent of cave cells that start as rock, and how many times the cave is smoothed
+	RockPercent int
+	SmoothSteps int
+}
+
+const DefaultGenerator = "rooms"
+
+var generators = map[string]func(Params) Generator{
+	"rooms": func(p Params) Generator { return &RoomsGenerator{p.clamped()} },
+	"bsp":   func(p Params) Generator { return &BSPGenerator{p.clamped()} },
+	"caves": func(p Params) Generator { return &CaveGenerator{p.clamped()} },
+	"vault": func(p Params) Generator { return &VaultGenerator{p.clamped()} },
+}
+
+// GetGenerator returns the named generator set up with the given params, falling back
+// to the default generator if there isn't one by that name
+func GetGenerator(name string, params Params) Generator {
+	if newGenerator, ok := generators[name]; ok {
+		return newGenerator(params)
+	}
+	log.Warnf("No map generator named %q, using %q", name, DefaultGenerator)
+	return generators[DefaultGenerator](params)
+}
+
+// GetGeneratorNames returns the names of all the map generators, sorted
+func GetGeneratorNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate makes a floor of the dungeon from a seed number with the named generator. Deeper
+// floors have more enemies, and the enemies on them are tougher. Misplaced creatures are
+// moved, and maps that still aren't valid after that are thrown away and made again.
+func Generate(name string, params Params, seed int64, floor int) *Map {
+	source := NewRandomSource(FloorSeed(seed, floor), 0)
+	random := rand.New(source)
+	generator := GetGenerator(name, params)
+
+	var level *Map
+	for attempt := 1; ; attempt++ {
+		level = generator.Generate(random, floor)
+		level.repairCreatures()
+		err := level.Validate()
+		if err == nil {
+			break
+		}
+		if attempt == maxGenerateAttempts {
+			log.Errorf("Couldn't generate a valid map after %d attempts, using the last one: %s", attempt, err)
+			break
+		}
+		log.Warnf("Generated an invalid map, trying again: %s", err)
+	}
+
+	level.Seed = seed
+	level.Random = random
+	level.RandomSource = source
+	level.Generator = name
+	level.Params = params
+	return level
+}
+
+// DefaultParams returns the params the game normally generates floors with
+func DefaultParams() Params {
+	return Params{
+		Length:        7,
+		ExtraLength:   5,
+		MinRoomSize:   5,
+		RoomSizeRange: 5,
+		Width:         48,
+		Height:        36,
+		RockPercent:   45,
+		SmoothSteps:   4,
+	}
+}
+
+// AddFlags adds a command line flag for each of the params, defaulting to their current values
+func (p *Params) AddFlags(flags *flag.FlagSet) {
+	flags.IntVar(&p.Length, "length", p.Length, "how many rooms deep the dungeon is, at least")
+	flags.IntVar(&p.ExtraLength, "extra-length", p.ExtraLength, "the most rooms added to the dungeon's length at random")
+	flags.IntVar(&p.MinRoomSize, "min-room-size", p.MinRoomSize, "the smallest width or height of a room")
+	flags.IntVar(&p.RoomSizeRange, "room-size-range", p.RoomSizeRange, "the most added to a room's width or height at random")
+	flags.IntVar(&p.Width, "map-width", p.Width, "the width of the area BSP and cave levels are carved out of")
+	flags.IntVar(&p.Height, "map-height", p.Height, "the height of the area BSP and cave levels are carved out of")
+	flags.IntVar(&p.RockPercent, "rock-percent", p.RockPercent, "the percent of cave cells that start as rock")
+	flags.IntVar(&p.SmoothSteps, "smooth-steps", p.SmoothSteps, "how many times caves are smoothed")
+}
+
+// The smallest rooms can be and still have space inside them
+const minRoomSize = 3
+
+// Returns the params with anything out of range raised or lowered to the closest value the
+// generators can work with
+func (p Params) clamped() Params {
+	p.Length = imath.Max(p.Length, 1)
+	p.ExtraLength = imath.Max(p.ExtraLength, 0)
+	p.MinRoomSize = imath.Max(p.MinRoomSize, minRoomSize)
+	p.RoomSizeRange = imath.Max(p.RoomSizeRange, 0)
+	p.Width = imath.Max(p.Width, p.MinRoomSize+2)
+	p.Height = imath.Max(p.Height, p.MinRoomSize+2)
+	p.RockPercent = imath.Min(imath.Max(p.RockPercent, 0), 100)
+	p.SmoothSteps = imath.Max(p.SmoothSteps, 0)
+	return p
+}
+
+// Returns a random dungeon length
+func (p Params) length(random *rand.Rand) int {
+	return p.Length + randomUpTo(random, p.ExtraLength)
+}
+
+// Returns a random room width or height
+func (p Params) roomSize(random *rand.Rand) int {
+	return p.MinRoomSize + randomUpTo(random, p.RoomSizeRange)
+}
+
+// Returns a random number from 0 up to but not including n, or 0 if n isn't positive
+// (where random.Intn would panic)
+func randomUpTo(random *rand.Rand, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return random.Intn(n)
+}
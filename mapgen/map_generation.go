@@ -3,10 +3,11 @@ package mapgen
 import (
 	"math/rand"
 	"sort"
+	"strings"
 
-	"engo.io/engo"
-	"engo.io/engo/common"
+	"engo.io/ecs"
 	log "github.com/Sirupsen/logrus"
+	"github.com/engoengine/math/imath"
 	"github.com/kyhavlov/go-dnd/structs"
 )
 
@@ -18,15 +19,71 @@ type RoomNode struct {
 	Height  int
 	visited bool
 	depth   int
+
+	// The layout of a prefab room, one string per row (see vaults.go). Rooms without
+	// a shape are open rectangles.
+	Shape []string
+
+	// The name of the room theme used to fill the room
+	Theme string
 }
 
 func (room *RoomNode) Contains(point structs.GridPoint) bool {
-	if room.X <= point.X && room.X+room.Width >= point.X && room.Y <= point.Y && room.Y+room.Height <= point.Y {
+	if room.X <= point.X && room.X+room.Width > point.X && room.Y <= point.Y && room.Y+room.Height > point.Y {
 		return true
 	}
 	return false
 }
 
+// IsOpen returns whether the point is inside the room and can be walked on
+func (room *RoomNode) IsOpen(point structs.GridPoint) bool {
+	return room.Contains(point) && room.cellAt(point) != solidCell
+}
+
+// How many random points RandomPoint tries before looking through the room in order
+const randomPointAttempts = 100
+
+// RandomPoint returns a random open point in the room. If it can't find one at random (such as
+// in a shaped room that's mostly solid), it returns the first open point from the top left, or
+// the corner of the room if nothing in it is open.
+func (room *RoomNode) RandomPoint(random *rand.Rand) structs.GridPoint {
+	for i := 0; i < randomPointAttempts; i++ {
+		point := structs.GridPoint{
+			X: room.X + random.Intn(room.Width),
+			Y: room.Y + random.Intn(room.Height),
+		}
+		if room.IsOpen(point) {
+			return point
+		}
+	}
+
+	for y := room.Y; y < room.Y+room.Height; y++ {
+		for x := room.X; x < room.X+room.Width; x++ {
+			if point := (structs.GridPoint{X: x, Y: y}); room.IsOpen(point) {
+				return point
+			}
+		}
+	}
+	return room.GridPoint
+}
+
+// Returns the character in the room's shape at the given point
+func (room *RoomNode) cellAt(point structs.GridPoint) byte {
+	if room.Shape == nil {
+		return floorCell
+	}
+	return room.Shape[point.Y-room.Y][point.X-room.X]
+}
+
+func (room *RoomNode) hasTorch() bool {
+	for _, row := range room.Shape {
+		if strings.IndexByte(row, torchCell) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
 type RoomQueue []*RoomNode
 
 func (q *RoomQueue) Push(n *RoomNode) {
@@ -55,12 +112,14 @@ func (m Rooms) Less(i, j int) bool {
 	return m[i].depth > m[j].depth
 }
 
-func addNewRoom(id int, random *rand.Rand, edgeRoom *RoomNode) (*RoomNode, []structs.GridPoint) {
+// Places a new room of the given size a random distance away from the edge room,
+// and returns it along with the hallway connecting the two
+func addNewRoom(id int, random *rand.Rand, edgeRoom *RoomNode, width, height int) (*RoomNode, []structs.GridPoint) {
 	newRoom := &RoomNode{
 		Neighbors: make(map[int]*RoomNode),
 		Id:        id,
-		Width:     5 + rand.Intn(5),
-		Height:    5 + rand.Intn(5),
+		Width:     width,
+		Height:    height,
 		depth:     edgeRoom.depth + 1,
 	}
 
@@ -90,8 +149,10 @@ func addNewRoom(id int, random *rand.Rand, edgeRoom *RoomNode) (*RoomNode, []str
 	start := structs.GridPoint{}
 	end := structs.GridPoint{}
 
+	// If the rooms overlap on an axis, run the hallway straight across somewhere in the overlap
 	if leftRoom.X+leftRoom.Width > rightRoom.X && leftRoom.X < rightRoom.X+rightRoom.Width {
-		start.X = rightRoom.X + random.Intn(leftRoom.X+leftRoom.Width-rightRoom.X)
+		overlapEnd := imath.Min(leftRoom.X+leftRoom.Width, rightRoom.X+rightRoom.Width)
+		start.X = rightRoom.X + random.Intn(overlapEnd-rightRoom.X)
 		end.X = start.X
 	} else {
 		start.X = leftRoom.X + random.Intn(leftRoom.Width)
@@ -99,7 +160,8 @@ func addNewRoom(id int, random *rand.Rand, edgeRoom *RoomNode) (*RoomNode, []str
 	}
 
 	if bottomRoom.Y+bottomRoom.Height > topRoom.Y && bottomRoom.Y < topRoom.Y+topRoom.Height {
-		start.Y = topRoom.Y + random.Intn(bottomRoom.Y+bottomRoom.Height-topRoom.Y)
+		overlapEnd := imath.Min(bottomRoom.Y+bottomRoom.Height, topRoom.Y+topRoom.Height)
+		start.Y = topRoom.Y + random.Intn(overlapEnd-topRoom.Y)
 		end.Y = start.Y
 	} else {
 		start.Y = bottomRoom.Y + random.Intn(bottomRoom.Height)
@@ -143,21 +205,105 @@ func roomIsValid(room *RoomNode, rooms []*RoomNode) bool {
 	return true
 }
 
+// Returns whether the hallway goes through any of the rooms (or their walls) other than the one it starts from
+func hallwayCrossesRooms(hallway []structs.GridPoint, rooms []*RoomNode, from *RoomNode) bool {
+	for _, room := range rooms {
+		if room == from {
+			continue
+		}
+		walls := &RoomNode{
+			GridPoint: structs.GridPoint{X: room.X - 1, Y: room.Y - 1},
+			Width:     room.Width + 2,
+			Height:    room.Height + 2,
+		}
+		for _, point := range hallway {
+			if walls.Contains(point) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type Map struct {
 	Tiles     []*structs.Tile
 	Creatures []*structs.Creature
+	Items     []*structs.Item
+	Lights    []*Light
+	Rooms     Rooms
 	Width     int
 	Height    int
 	StartLoc  structs.GridPoint
+
+	// Where each player starts on the floor, indexed by player ID
+	SpawnPoints []structs.GridPoint
+
+	// The seed of the whole dungeon, and which floor of it this is (starting at 1)
+	Seed  int64
+	Floor int
+
+	// The generator used to make the dungeon, so deeper floors are made the same way
+	Generator string
+	Params    Params
+
+	// The random source the map was made with, for anything else on the floor that needs to
+	// be random but the same on every client, and the state of it for saving the game
+	Random       *rand.Rand
+	RandomSource *RandomSource
+}
+
+// Light is a light source on the map that isn't attached to a tile, item or creature
+type Light struct {
+	ecs.BasicEntity `json:"-"`
+	structs.GridPoint
+	structs.LightComponent
+}
+
+// The most players a game can have
+const MaxPlayers = 4
+
+// The most stairs tiles placed on a floor, which limits how many players can descend together
+const MaxStairs = MaxPlayers
+
+// FloorSeed derives the seed used to generate a given floor of the dungeon
+func FloorSeed(seed int64, floor int) int64 {
+	if floor <= 1 {
+		return seed
+	}
+	return rand.New(rand.NewSource(seed + int64(floor))).Int63()
+}
+
+// Returns the room containing the given point, or nil if it isn't in a room
+func (m *Map) GetRoomAt(point structs.GridPoint) *RoomNode {
+	for _, room := range m.Rooms {
+		if room.Contains(point) {
+			return room
+		}
+	}
+	return nil
+}
+
+// RoomsGenerator is the original dungeon layout: rooms are grown outwards from the starting
+// room, each joined to an existing one by a hallway, until the dungeon is deep enough.
+type RoomsGenerator struct {
+	Params Params
+}
+
+func (g *RoomsGenerator) Generate(random *rand.Rand, floor int) *Map {
+	params := g.Params
+	rooms, start, hallways := growDungeon(random, params.length(random), func(id int, edgeRoom *RoomNode) (*RoomNode, []structs.GridPoint) {
+		return addNewRoom(id, random, edgeRoom, params.roomSize(random), params.roomSize(random))
+	})
+	return buildLevel(random, rooms, start, hallways, floor)
 }
 
-// Generates a map from a seed number
-func GenerateMap(seed int64) *Map {
-	random := rand.New(rand.NewSource(seed))
+// Grows a dungeon out from a small starting room until the deepest room is the given number of
+// rooms away from it. newRoom is called to place each new room next to an existing one.
+// Returns the rooms sorted deepest first, the starting room and the hallways between them.
+func growDungeon(random *rand.Rand, dungeonLength int, newRoom func(id int, edgeRoom *RoomNode) (*RoomNode, []structs.GridPoint)) (Rooms, *RoomNode, []structs.GridPoint) {
 	var rooms Rooms
 	var hallways []structs.GridPoint
 
-	dungeonLength := 7 + random.Intn(5)
 	idInc := 0
 
 	startingRoom := &RoomNode{
@@ -174,22 +320,22 @@ func GenerateMap(seed int64) *Map {
 	// Add rooms until the dungeon is the desired length
 	for depthReached < dungeonLength {
 		idInc++
-		newRoom := &RoomNode{}
-		hallway := make([]structs.GridPoint, 0)
+		var room *RoomNode
+		var hallway []structs.GridPoint
 		madeNewRoom := false
 
 		// try to spawn a new room, starting from the furthest room
 		for _, edgeRoom := range rooms {
-			newRoom, hallway = addNewRoom(idInc, random, edgeRoom)
+			room, hallway = newRoom(idInc, edgeRoom)
 
-			if !roomIsValid(newRoom, rooms) {
+			if !roomIsValid(room, rooms) || hallwayCrossesRooms(hallway, rooms, edgeRoom) {
 				continue
 			}
 
-			edgeRoom.Neighbors[newRoom.Id] = newRoom
-			newRoom.Neighbors[edgeRoom.Id] = edgeRoom
+			edgeRoom.Neighbors[room.Id] = room
+			room.Neighbors[edgeRoom.Id] = edgeRoom
 
-			rooms = append(rooms, newRoom)
+			rooms = append(rooms, room)
 			hallways = append(hallways, hallway...)
 
 			madeNewRoom = true
@@ -201,43 +347,49 @@ func GenerateMap(seed int64) *Map {
 			continue
 		}
 
-		log.Debugf("Added new room with id %d and depth %d", newRoom.Id, newRoom.depth)
+		log.Debugf("Added new room with id %d and depth %d", room.Id, room.depth)
 
-		// Recalculate the depth of the rooms (the number of rooms away from the start they are)
-		for _, room := range rooms {
-			room.visited = false
-		}
+		setDepths(startingRoom, rooms)
+		depthReached = rooms[0].depth
+	}
 
-		queue := make(RoomQueue, 0)
+	return rooms, startingRoom, hallways
+}
 
-		queue.Push(startingRoom)
+// Recalculates the depth of the rooms (the number of rooms away from the start they are)
+// and sorts them deepest first
+func setDepths(startingRoom *RoomNode, rooms Rooms) {
+	for _, room := range rooms {
+		room.visited = false
+	}
 
-		for queue.Len() > 0 {
-			current := queue.Pop()
-			if current.visited {
-				continue
-			}
+	queue := make(RoomQueue, 0)
 
-			current.visited = true
+	startingRoom.depth = 1
+	queue.Push(startingRoom)
 
-			/*spaces := ""
-			for i := 0; i < current.depth; i++ {
-				spaces = spaces + "-"
-			}
-			log.Infof("%s %d", spaces, current.Id)*/
+	for queue.Len() > 0 {
+		current := queue.Pop()
+		if current.visited {
+			continue
+		}
 
-			for _, neighbor := range current.Neighbors {
-				if !neighbor.visited {
-					neighbor.depth = current.depth + 1
-					queue.Push(neighbor)
-				}
+		current.visited = true
+
+		for _, neighbor := range current.Neighbors {
+			if !neighbor.visited {
+				neighbor.depth = current.depth + 1
+				queue.Push(neighbor)
 			}
 		}
-
-		sort.Sort(rooms)
-		depthReached = rooms[0].depth
 	}
 
+	sort.Stable(rooms)
+}
+
+// Turns a layout of rooms and the corridors between them into a level, aligning it to 0,0 and
+// filling it with tiles, lights, stairs and enemies. The rooms must be sorted deepest first.
+func buildLevel(random *rand.Rand, rooms Rooms, startingRoom *RoomNode, corridors []structs.GridPoint, floor int) *Map {
 	// Get the minimum X/Y values rooms were placed at so we can align the level to 0,0
 	offset := structs.GridPoint{
 		X: startingRoom.X,
@@ -247,34 +399,35 @@ func GenerateMap(seed int64) *Map {
 	// and the maximum X/Y values so we know the bounds of the level
 	maxPoint := structs.GridPoint{}
 
+	// Leave space for the walls around the outside of the rooms
 	for _, room := range rooms {
-		if room.X < offset.X {
-			offset.X = room.X
+		if room.X-1 < offset.X {
+			offset.X = room.X - 1
 			log.Debugf("New min X room: id %d gridpoint %v", room.Id, room.GridPoint)
 		}
-		if room.Y < offset.Y {
-			offset.Y = room.Y
+		if room.Y-1 < offset.Y {
+			offset.Y = room.Y - 1
 		}
-		if room.X+room.Width > maxPoint.X {
-			maxPoint.X = room.X + room.Width
+		if room.X+room.Width+1 > maxPoint.X {
+			maxPoint.X = room.X + room.Width + 1
 		}
-		if room.Y+room.Height > maxPoint.Y {
-			maxPoint.Y = room.Y + room.Height
+		if room.Y+room.Height+1 > maxPoint.Y {
+			maxPoint.Y = room.Y + room.Height + 1
 		}
 	}
 
-	for _, tile := range hallways {
+	for _, tile := range corridors {
 		if tile.X < offset.X {
 			offset.X = tile.X
 		}
 		if tile.Y < offset.Y {
 			offset.Y = tile.Y
 		}
-		if tile.X > maxPoint.X {
-			maxPoint.X = tile.X
+		if tile.X+1 > maxPoint.X {
+			maxPoint.X = tile.X + 1
 		}
-		if tile.Y > maxPoint.Y {
-			maxPoint.Y = tile.Y
+		if tile.Y+1 > maxPoint.Y {
+			maxPoint.Y = tile.Y + 1
 		}
 	}
 
@@ -284,22 +437,49 @@ func GenerateMap(seed int64) *Map {
 	level := &Map{
 		Width:  maxPoint.X - offset.X,
 		Height: maxPoint.Y - offset.Y,
+		Floor:  floor,
 	}
 
-	common.CameraBounds.Max = engo.Point{
-		X: float32(level.Width * structs.TileWidth),
-		Y: float32(level.Height * structs.TileWidth),
+	// Only the first tile added at a location is kept, so the stairs go in before anything else
+	placed := make(map[structs.GridPoint]bool)
+	addTile := func(name string, loc structs.GridPoint) {
+		if !placed[loc] {
+			placed[loc] = true
+			level.Tiles = append(level.Tiles, structs.NewTile(name, loc, random))
+		}
 	}
 
-	// Add tiles for the map based on the rooms generated
-	sheet := common.NewSpritesheetFromFile(structs.SpritesheetPath, structs.TileWidth, structs.TileWidth)
-	if sheet == nil {
-		log.Fatalf("Unable to load texture file")
-	}
 	for _, room := range rooms {
 		room.X -= offset.X
 		room.Y -= offset.Y
 		log.Debug(room)
+	}
+
+	// The stairs down go along the bottom of the deepest room, which is first since the rooms
+	// are sorted by depth
+	bossRoom := rooms[0]
+	for i := 0; i < bossRoom.Width && i < MaxStairs; i++ {
+		loc := structs.GridPoint{
+			X: bossRoom.X + i,
+			Y: bossRoom.Y + bossRoom.Height - 1,
+		}
+		if bossRoom.IsOpen(loc) {
+			addTile("Stairs Down", loc)
+		}
+	}
+
+	// Add tiles for the map based on the rooms generated
+	var walls []structs.GridPoint
+	for _, room := range rooms {
+		// Light each room with a torch somewhere along its top edge, unless its shape
+		// already has one
+		torch := structs.GridPoint{
+			X: room.X + random.Intn(room.Width),
+			Y: room.Y,
+		}
+		if room.hasTorch() {
+			torch = structs.GridPoint{X: -1, Y: -1}
+		}
 		for i := 0; i < room.Width; i++ {
 			for j := 0; j < room.Height; j++ {
 				loc := structs.GridPoint{
@@ -307,35 +487,194 @@ func GenerateMap(seed int64) *Map {
 					Y: room.Y + j,
 				}
 
-				level.Tiles = append(level.Tiles, structs.NewTile("Dungeon Floor", loc))
+				switch {
+				case !room.IsOpen(loc):
+					walls = append(walls, loc)
+				case loc == torch || room.cellAt(loc) == torchCell:
+					addTile("Wall Torch", loc)
+				default:
+					addTile("Dungeon Floor", loc)
+				}
 			}
 		}
 	}
 
 	level.StartLoc = startingRoom.GridPoint
+	level.SpawnPoints = startingRoom.spawnPoints()
+	level.Rooms = rooms
 
-	// Next, do the hallways
-	for _, tile := range hallways {
-		tile.X -= offset.X
-		tile.Y -= offset.Y
-
-		level.Tiles = append(level.Tiles, structs.NewTile("Dungeon Floor", tile))
+	for i := range corridors {
+		corridors[i].X -= offset.X
+		corridors[i].Y -= offset.Y
 	}
 
-	// Spawn creatures in some of the rooms
+	// Put doors where the corridors lead into rooms, and walls around the rest of the room
+	isCorridor := make(map[structs.GridPoint]bool)
+	for _, tile := range corridors {
+		isCorridor[tile] = true
+	}
 	for _, room := range rooms {
-		if random.Intn(2) == 0 && room != startingRoom {
-			count := 1 + random.Intn(4)
-			for i := 0; i < count; i++ {
-				coords := structs.GridPoint{
-					X: room.X + random.Intn(room.Width),
-					Y: room.Y + random.Intn(room.Height),
-				}
-				creature := structs.NewCreature("Skeleton", coords)
-				level.Creatures = append(level.Creatures, creature)
+		for _, loc := range room.border() {
+			if isCorridor[loc] && isDoorway(loc, room, isCorridor) {
+				addTile("Door", loc)
+			} else if !isCorridor[loc] {
+				walls = append(walls, loc)
 			}
 		}
 	}
 
+	// Next, do the corridors. They're added before the walls so they can cut through a room's shape
+	for _, tile := range corridors {
+		addTile("Dungeon Floor", tile)
+	}
+	for _, loc := range walls {
+		addTile("Dungeon Wall", loc)
+	}
+
+	// Put a boss in the deepest room
+	if bosses := structs.GetBossNames(); len(bosses) > 0 {
+		coords := structs.GridPoint{
+			X: bossRoom.X + bossRoom.Width/2,
+			Y: bossRoom.Y + bossRoom.Height/2,
+		}
+		if !bossRoom.IsOpen(coords) {
+			coords = bossRoom.RandomPoint(random)
+		}
+		boss := structs.NewCreature(bosses[random.Intn(len(bosses))], coords)
+		level.Creatures = append(level.Creatures, boss)
+	}
+
+	// Fill the rest of the rooms based on their themes
+	for _, room := range rooms {
+		if room != startingRoom && room != bossRoom {
+			level.populateRoom(random, room)
+		}
+	}
+
+	for _, creature := range level.Creatures {
+		scaleForFloor(creature, floor)
+	}
+
 	return level
 }
+
+// Picks a theme for the room and spawns its creatures, items and light, with more creatures
+// on deeper floors
+func (m *Map) populateRoom(random *rand.Rand, room *RoomNode) {
+	themes := structs.GetRoomThemes()
+	var table structs.SpawnTable
+	for _, theme := range themes {
+		table = append(table, structs.SpawnEntry{Name: theme.Name, Weight: theme.Weight})
+	}
+	name := table.Roll(random)
+	if name == "" {
+		return
+	}
+
+	var theme structs.RoomTheme
+	for _, t := range themes {
+		if t.Name == name {
+			theme = t
+		}
+	}
+	room.Theme = theme.Name
+
+	count := theme.Creatures + random.Intn(theme.ExtraCreatures+1)
+	if count > 0 {
+		count += m.Floor - 1
+	}
+	for i := 0; i < count && len(theme.CreatureTable) > 0; i++ {
+		creature := structs.NewCreature(theme.CreatureTable.Roll(random), room.RandomPoint(random))
+		m.Creatures = append(m.Creatures, creature)
+	}
+
+	count = theme.Items + random.Intn(theme.ExtraItems+1)
+	for i := 0; i < count && len(theme.ItemTable) > 0; i++ {
+		name := theme.ItemTable.Roll(random)
+		m.Items = append(m.Items, structs.RollItem(name, room.RandomPoint(random), random))
+	}
+
+	// Hazards replace plain floor that nothing has been put on
+	occupied := make(map[structs.GridPoint]bool)
+	for _, creature := range m.Creatures {
+		occupied[structs.PointToGridPoint(creature.Position)] = true
+	}
+	count = theme.Hazards + random.Intn(theme.ExtraHazards+1)
+	for i := 0; i < count && len(theme.HazardTable) > 0; i++ {
+		name := theme.HazardTable.Roll(random)
+		point := room.RandomPoint(random)
+		for j, tile := range m.Tiles {
+			if tile.GridPoint == point && !occupied[point] && tile.Walkable && !tile.Stairs && !tile.Light.IsLit() && !tile.Hazard.IsHazard() {
+				m.Tiles[j] = structs.NewTile(name, point, random)
+				break
+			}
+		}
+	}
+
+	if theme.Light.Brightness > 0 {
+		center := structs.GridPoint{X: room.X + room.Width/2, Y: room.Y + room.Height/2}
+		if !room.IsOpen(center) {
+			center = room.RandomPoint(random)
+		}
+		m.Lights = append(m.Lights, &Light{GridPoint: center, LightComponent: theme.Light})
+	}
+}
+
+// Returns the spots for players to start in the room, filling it from the bottom row up
+func (room *RoomNode) spawnPoints() []structs.GridPoint {
+	var points []structs.GridPoint
+	for j := room.Height - 1; j >= 0; j-- {
+		for i := 0; i < room.Width; i++ {
+			loc := structs.GridPoint{X: room.X + i, Y: room.Y + j}
+			if len(points) < MaxPlayers && room.IsOpen(loc) {
+				points = append(points, loc)
+			}
+		}
+	}
+	return points
+}
+
+// Returns the points in the ring just outside the room
+func (room *RoomNode) border() []structs.GridPoint {
+	var points []structs.GridPoint
+	for i := -1; i <= room.Width; i++ {
+		points = append(points,
+			structs.GridPoint{X: room.X + i, Y: room.Y - 1},
+			structs.GridPoint{X: room.X + i, Y: room.Y + room.Height})
+	}
+	for j := 0; j < room.Height; j++ {
+		points = append(points,
+			structs.GridPoint{X: room.X - 1, Y: room.Y + j},
+			structs.GridPoint{X: room.X + room.Width, Y: room.Y + j})
+	}
+	return points
+}
+
+// Returns whether a corridor tile on the room's border is a one tile wide opening into it,
+// rather than part of a corridor running along the wall
+func isDoorway(loc structs.GridPoint, room *RoomNode, isCorridor map[structs.GridPoint]bool) bool {
+	onSide := loc.X == room.X-1 || loc.X == room.X+room.Width
+	onEnd := loc.Y == room.Y-1 || loc.Y == room.Y+room.Height
+	if onSide && onEnd {
+		// Doors can't go in the corners
+		return false
+	}
+
+	var sides [2]structs.GridPoint
+	if onEnd {
+		sides = [2]structs.GridPoint{{X: loc.X - 1, Y: loc.Y}, {X: loc.X + 1, Y: loc.Y}}
+	} else {
+		sides = [2]structs.GridPoint{{X: loc.X, Y: loc.Y - 1}, {X: loc.X, Y: loc.Y + 1}}
+	}
+	return !isCorridor[sides[0]] && !isCorridor[sides[1]]
+}
+
+// Makes a creature tougher based on how deep in the dungeon it is
+func scaleForFloor(creature *structs.Creature, floor int) {
+	bonus := floor - 1
+	creature.MaxLife += 5 * bonus
+	creature.Life = creature.MaxLife
+	creature.Strength += bonus
+	creature.Dexterity += bonus
+	creature.Intelligence += bonus
+}
@@ -0,0 +1,229 @@
+package mapgen
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"engo.io/engo"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+var update = flag.Bool("update", false, "update the golden map files in testdata")
+
+const goldenSeed = 34343421999
+
+func TestMain(m *testing.M) {
+	// Tiles send a message when their render component is set up, which needs a mailbox
+	// to go to even without a game window
+	engo.Mailbox = &engo.MessageManager{}
+	os.Exit(m.Run())
+}
+
+// Draws the map's tiles and lists where everything on it is, so maps can be compared
+func describeMap(level *Map) string {
+	grid := make([][]byte, level.Height)
+	for y := range grid {
+		grid[y] = bytes.Repeat([]byte{' '}, level.Width)
+	}
+
+	sprites := fnv.New64a()
+	for _, tile := range level.Tiles {
+		grid[tile.Y][tile.X] = TileChar(tile.Name)
+		fmt.Fprintf(sprites, "%v%d", tile.GridPoint, tile.Sprite)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "size %dx%d, start %v, sprites %x\n", level.Width, level.Height, level.StartLoc, sprites.Sum64())
+	for _, row := range grid {
+		out.Write(bytes.TrimRight(row, " "))
+		out.WriteByte('\n')
+	}
+	for _, creature := range level.Creatures {
+		fmt.Fprintf(&out, "%s at %v\n", creature.Name, structs.PointToGridPoint(creature.Position))
+	}
+	for _, item := range level.Items {
+		fmt.Fprintf(&out, "item %s (%s) at %v\n", item.DisplayName(), item.Rarity, structs.PointToGridPoint(item.Position))
+	}
+	return out.String()
+}
+
+func TestGenerateGolden(t *testing.T) {
+	if err := structs.LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range GetGeneratorNames() {
+		for floor := 1; floor <= 2; floor++ {
+			actual := describeMap(Generate(name, DefaultParams(), goldenSeed, floor))
+			if again := describeMap(Generate(name, DefaultParams(), goldenSeed, floor)); again != actual {
+				t.Fatalf("%s floor %d: generating the same seed twice gave different maps:\n%s\n%s", name, floor, actual, again)
+			}
+
+			golden := filepath.Join("testdata", fmt.Sprintf("%s_floor%d.golden", name, floor))
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(actual), 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != string(expected) {
+				t.Errorf("%s floor %d: map doesn't match %s (run with -update if the change is intended):\n%s", name, floor, golden, actual)
+			}
+		}
+	}
+}
+
+func TestValidateRepairsCreatures(t *testing.T) {
+	if err := structs.LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	level := Generate(DefaultGenerator, DefaultParams(), goldenSeed, 1)
+	if err := level.Validate(); err != nil {
+		t.Fatalf("generated map should be valid: %s", err)
+	}
+
+	// Stack two creatures on top of a player's spawn point
+	if len(level.Creatures) < 2 {
+		t.Fatalf("bad: %d creatures", len(level.Creatures))
+	}
+	level.Creatures[0].Position = level.SpawnPoints[0].ToPixels()
+	level.Creatures[1].Position = level.SpawnPoints[0].ToPixels()
+	if err := level.Validate(); err == nil {
+		t.Fatal("expected an error for creatures on a spawn point")
+	}
+
+	count := len(level.Creatures)
+	level.repairCreatures()
+	if err := level.Validate(); err != nil {
+		t.Fatalf("repaired map should be valid: %s", err)
+	}
+	if len(level.Creatures) != count {
+		t.Fatalf("bad: %d creatures, expected %d", len(level.Creatures), count)
+	}
+}
+
+func TestMapFileRoundTrip(t *testing.T) {
+	if err := structs.LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range GetGeneratorNames() {
+		level := Generate(name, DefaultParams(), goldenSeed, 2)
+		raw, err := level.EncodeMapFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := ParseMapFile(raw)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		// Tile icons are picked randomly, so leave out the first line with the sprite hash
+		expected := strings.SplitN(describeMap(level), "\n", 2)[1]
+		actual := strings.SplitN(describeMap(loaded), "\n", 2)[1]
+		if actual != expected {
+			t.Errorf("%s: loaded map doesn't match the saved one:\n%s\n%s", name, expected, actual)
+		}
+		if loaded.Floor != level.Floor || loaded.StartLoc != level.StartLoc || len(loaded.SpawnPoints) != len(level.SpawnPoints) {
+			t.Errorf("%s: bad: floor %d, start %v, %d spawn points", name, loaded.Floor, loaded.StartLoc, len(loaded.SpawnPoints))
+		}
+	}
+}
+
+func TestLoadMapFile(t *testing.T) {
+	if err := structs.LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	level, err := LoadMapFile("../maps/tutorial.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(level.Creatures) != 2 || len(level.Items) != 2 || len(level.Lights) != 1 || len(level.SpawnPoints) != MaxPlayers {
+		t.Fatalf("bad: %d creatures, %d items, %d lights, %d spawn points", len(level.Creatures), len(level.Items), len(level.Lights), len(level.SpawnPoints))
+	}
+
+	cases := []string{
+		`{"legend": {"#": "Lava"}, "tiles": ["#"]}`,
+		`{"legend": {"..": "Dungeon Floor"}, "tiles": ["."]}`,
+		`{"legend": {".": "Dungeon Floor"}, "tiles": ["..x.."]}`,
+		`{"legend": {".": "Dungeon Floor"}, "tiles": ["....."], "creatures": [{"name": "Dragon", "x": 4, "y": 0}]}`,
+		`{"legend": {".": "Dungeon Floor"}, "tiles": ["....."], "items": [{"name": "Torch", "x": 9, "y": 0}]}`,
+		`{"legend": {".": "Dungeon Floor"}, "tiles": ["..."]}`,
+	}
+	for _, raw := range cases {
+		if _, err := ParseMapFile([]byte(raw)); err == nil {
+			t.Errorf("expected an error loading %s", raw)
+		}
+	}
+}
+
+func TestGenerateSmallParams(t *testing.T) {
+	if err := structs.LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Params too small to be usable get raised to the smallest ones that work
+	small := Params{Length: 2, MinRoomSize: 3, Width: 12, Height: 10}
+	for _, name := range GetGeneratorNames() {
+		for _, params := range []Params{{}, small} {
+			if level := Generate(name, params, goldenSeed, 1); len(level.Tiles) == 0 {
+				t.Errorf("%s: bad: no tiles with params %+v", name, params)
+			}
+		}
+	}
+
+	// BSP leaves smaller than the rooms get rooms shrunk to fit inside their border
+	bsp := &BSPGenerator{DefaultParams()}
+	var rooms Rooms
+	for _, size := range []int{3, 4, 5} {
+		node := &bspNode{GridPoint: structs.GridPoint{X: 10, Y: 10}, Width: size, Height: size}
+		bsp.placeRooms(rand.New(rand.NewSource(1)), node, &rooms)
+		room := node.room
+		if room.Width < 1 || room.X <= node.X || room.X+room.Width >= node.X+node.Width {
+			t.Errorf("bad: room %+v in node %+v", room.GridPoint, node.GridPoint)
+		}
+	}
+
+	// Zero is a setting of its own rather than meaning the default
+	cave := &CaveGenerator{Params{Width: 10, Height: 8, RockPercent: 0}.clamped()}
+	rock := cave.fill(rand.New(rand.NewSource(1)))
+	for x := 1; x < 9; x++ {
+		for y := 1; y < 7; y++ {
+			if rock[x][y] {
+				t.Fatalf("bad: rock at %d,%d with no rock percent", x, y)
+			}
+		}
+	}
+}
+
+func TestRandomSourceResume(t *testing.T) {
+	if err := structs.LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	level := Generate(DefaultGenerator, DefaultParams(), goldenSeed, 2)
+	level.Random.Intn(100)
+
+	// A source made with the same seed and draws picks up where the level's left off
+	resumed := rand.New(NewRandomSource(level.RandomSource.StartSeed, level.RandomSource.Draws))
+	for i := 0; i < 10; i++ {
+		if a, b := level.Random.Int63(), resumed.Int63(); a != b {
+			t.Fatalf("bad: draw %d was %d, not %d", i, b, a)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package mapgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// MapFile is the format hand-made maps are saved in. The tiles are drawn as rows of
+// characters, with the legend saying which tile each character stands for. Spaces are
+// left empty.
+type MapFile struct {
+	Legend map[string]string `json:"legend"`
+	Tiles  []string          `json:"tiles"`
+
+	Start structs.GridPoint `json:"start"`
+	// Where each player starts. If left out, the closest tiles to the start are used
+	SpawnPoints []structs.GridPoint `json:"spawn_points,omitempty"`
+
+	Creatures []MapObject `json:"creatures,omitempty"`
+	Items     []MapObject `json:"items,omitempty"`
+	Lights    []*Light    `json:"lights,omitempty"`
+
+	// Which floor of the dungeon this is, and how to generate the floors below it. The seed
+	// also picks which icon each tile uses.
+	Floor     int    `json:"floor,omitempty"`
+	Seed      int64  `json:"seed,omitempty"`
+	Generator string `json:"generator,omitempty"`
+	Params    Params `json:"params,omitempty"`
+}
+
+// MapObject is a creature or item placed on the map
+type MapObject struct {
+	Name string `json:"name"`
+	structs.GridPoint
+
+	// The rarity and affixes of an item, if it has any
+	Rarity  string   `json:"rarity,omitempty"`
+	Affixes []string `json:"affixes,omitempty"`
+}
+
+// The characters used for the standard tiles when saving a map
+var defaultLegend = map[string]string{
+	"Dungeon Floor": ".",
+	"Dungeon Wall":  "#",
+	"Door":          "+",
+	"Open Door":     "'",
+	"Stairs Down":   ">",
+	"Wall Torch":    "T",
+	"Spike Trap":    "^",
+	"Fire":          "~",
+	"Poison Gas":    "%",
+	"Pit":           "O",
+}
+
+// Characters handed out to any other tiles when saving a map
+const spareLegendChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSUVWXYZ0123456789"
+
+// TileChar returns the character a kind of tile is drawn with, or '?' if it doesn't have one
+func TileChar(name string) byte {
+	if char, ok := defaultLegend[name]; ok {
+		return char[0]
+	}
+	return '?'
+}
+
+// LoadMapFile reads a hand-made map from the file at the given path
+func LoadMapFile(path string) (*Map, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading map file: %s", err)
+	}
+	level, err := ParseMapFile(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading map file %s: %s", path, err)
+	}
+	return level, nil
+}
+
+// SaveMapFile writes the map to the file at the given path
+func (m *Map) SaveMapFile(path string) error {
+	raw, err := m.EncodeMapFile()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, raw, 0644)
+}
+
+// ParseMapFile builds a map from the contents of a map file, checking that everything in it
+// exists in the game data and that the map is playable
+func ParseMapFile(raw []byte) (*Map, error) {
+	// Params the file leaves out keep their defaults
+	file := MapFile{Params: DefaultParams()}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, err
+	}
+
+	if file.Floor < 1 {
+		file.Floor = 1
+	}
+	if file.Generator == "" {
+		file.Generator = DefaultGenerator
+	}
+
+	source := NewRandomSource(FloorSeed(file.Seed, file.Floor), 0)
+	random := rand.New(source)
+	level := &Map{
+		Height:       len(file.Tiles),
+		StartLoc:     file.Start,
+		SpawnPoints:  file.SpawnPoints,
+		Lights:       file.Lights,
+		Seed:         file.Seed,
+		Floor:        file.Floor,
+		Generator:    file.Generator,
+		Params:       file.Params,
+		Random:       random,
+		RandomSource: source,
+	}
+
+	for char, name := range file.Legend {
+		if len(char) != 1 || char == " " {
+			return nil, fmt.Errorf("legend key '%s' should be a single character other than a space", char)
+		}
+		if structs.GetTileData(name).Name == "" {
+			return nil, fmt.Errorf("legend has unrecognized tile: '%s'", name)
+		}
+	}
+
+	for y, row := range file.Tiles {
+		if len(row) > level.Width {
+			level.Width = len(row)
+		}
+		for x, char := range row {
+			if char == ' ' {
+				continue
+			}
+			name, ok := file.Legend[string(char)]
+			if !ok {
+				return nil, fmt.Errorf("tile '%c' at %d,%d isn't in the legend", char, x, y)
+			}
+			level.Tiles = append(level.Tiles, structs.NewTile(name, structs.GridPoint{X: x, Y: y}, random))
+		}
+	}
+
+	for _, object := range file.Creatures {
+		if structs.GetCreatureData(object.Name).Name == "" {
+			return nil, fmt.Errorf("unrecognized creature: '%s'", object.Name)
+		}
+		creature := structs.NewCreature(object.Name, object.GridPoint)
+		scaleForFloor(creature, level.Floor)
+		level.Creatures = append(level.Creatures, creature)
+	}
+
+	for _, object := range file.Items {
+		if structs.GetItemData(object.Name).Name == "" {
+			return nil, fmt.Errorf("unrecognized item: '%s'", object.Name)
+		}
+		if !level.inBounds(object.GridPoint) {
+			return nil, fmt.Errorf("item %s at %v is off the map", object.Name, object.GridPoint)
+		}
+		item := structs.NewItem(object.Name, object.GridPoint)
+		item.OnGround = true
+		item.Rarity = object.Rarity
+		for _, name := range object.Affixes {
+			affix := structs.GetAffix(name)
+			if affix.Name == "" {
+				return nil, fmt.Errorf("item %s has unrecognized affix: '%s'", object.Name, name)
+			}
+			item.ApplyAffix(affix)
+		}
+		level.Items = append(level.Items, item)
+	}
+
+	if len(level.SpawnPoints) == 0 {
+		level.SpawnPoints = level.nearestSpawnPoints()
+	}
+
+	if err := level.Validate(); err != nil {
+		return nil, err
+	}
+
+	return level, nil
+}
+
+// EncodeMapFile saves the map in the map file format
+func (m *Map) EncodeMapFile() ([]byte, error) {
+	file := MapFile{
+		Legend:      make(map[string]string),
+		Start:       m.StartLoc,
+		SpawnPoints: m.SpawnPoints,
+		Lights:      m.Lights,
+		Floor:       m.Floor,
+		Seed:        m.Seed,
+		Generator:   m.Generator,
+		Params:      m.Params,
+	}
+
+	// Work out which character to draw each kind of tile with
+	chars := make(map[string]byte)
+	var names []string
+	for _, tile := range m.Tiles {
+		if _, ok := chars[tile.Name]; !ok {
+			chars[tile.Name] = 0
+			names = append(names, tile.Name)
+		}
+	}
+	sort.Strings(names)
+	spare := spareLegendChars
+	for _, name := range names {
+		char, ok := defaultLegend[name]
+		if !ok {
+			if len(spare) == 0 {
+				return nil, fmt.Errorf("too many kinds of tile to save the map")
+			}
+			char, spare = spare[:1], spare[1:]
+		}
+		chars[name] = char[0]
+		file.Legend[char] = name
+	}
+
+	grid := make([][]byte, m.Height)
+	for y := range grid {
+		grid[y] = []byte(strings.Repeat(" ", m.Width))
+	}
+	for _, column := range m.tileGrid() {
+		for _, tile := range column {
+			if tile != nil {
+				grid[tile.Y][tile.X] = chars[tile.Name]
+			}
+		}
+	}
+	for _, row := range grid {
+		file.Tiles = append(file.Tiles, strings.TrimRight(string(row), " "))
+	}
+
+	for _, creature := range m.Creatures {
+		file.Creatures = append(file.Creatures, MapObject{
+			Name:      creature.Name,
+			GridPoint: structs.PointToGridPoint(creature.Position),
+		})
+	}
+	for _, item := range m.Items {
+		file.Items = append(file.Items, MapObject{
+			Name:      item.Name,
+			GridPoint: structs.PointToGridPoint(item.Position),
+			Rarity:    item.Rarity,
+			Affixes:   item.Affixes,
+		})
+	}
+
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// Picks the closest walkable tiles to the start for the players to spawn on
+func (m *Map) nearestSpawnPoints() []structs.GridPoint {
+	tiles := m.tileGrid()
+	var points []structs.GridPoint
+	if !m.inBounds(m.StartLoc) {
+		return points
+	}
+
+	seen := map[structs.GridPoint]bool{m.StartLoc: true}
+	queue := []structs.GridPoint{m.StartLoc}
+	for len(queue) > 0 && len(points) < MaxPlayers {
+		current := queue[0]
+		queue = queue[1:]
+		if !isWalkable(tiles[current.X][current.Y]) {
+			continue
+		}
+		points = append(points, current)
+		for _, next := range neighbors(current) {
+			if m.inBounds(next) && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return points
+}
@@ -0,0 +1,38 @@
+package mapgen
+
+import "math/rand"
+
+// RandomSource is a seeded source of random numbers that counts how many it's given out, so
+// a saved game can put it back the way it was by drawing that many again
+type RandomSource struct {
+	StartSeed int64
+	Draws     uint64
+
+	source rand.Source64
+}
+
+// NewRandomSource returns a source seeded with the given seed, after the given number of draws
+func NewRandomSource(seed int64, draws uint64) *RandomSource {
+	r := &RandomSource{}
+	r.Seed(seed)
+	for r.Draws < draws {
+		r.Int63()
+	}
+	return r
+}
+
+func (r *RandomSource) Int63() int64 {
+	r.Draws++
+	return r.source.Int63()
+}
+
+func (r *RandomSource) Uint64() uint64 {
+	r.Draws++
+	return r.source.Uint64()
+}
+
+func (r *RandomSource) Seed(seed int64) {
+	r.StartSeed = seed
+	r.Draws = 0
+	r.source = rand.NewSource(seed).(rand.Source64)
+}
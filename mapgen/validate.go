@@ -0,0 +1,166 @@
+package mapgen
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// How many times to regenerate a map that fails validation before giving up and using it anyway
+const maxGenerateAttempts = 10
+
+// Validate checks that the map is playable: every player has their own spot to start on,
+// everything in the rooms can be reached from the start, and every creature is standing on
+// its own walkable tile. Returns the first problem found.
+func (m *Map) Validate() error {
+	tiles := m.tileGrid()
+	reachable := m.reachable(tiles)
+
+	spawns := make(map[structs.GridPoint]bool)
+	if len(m.SpawnPoints) < MaxPlayers {
+		return fmt.Errorf("only %d spawn points for %d players", len(m.SpawnPoints), MaxPlayers)
+	}
+	for _, point := range m.SpawnPoints {
+		if !m.inBounds(point) || !isWalkable(tiles[point.X][point.Y]) {
+			return fmt.Errorf("spawn point %v isn't walkable", point)
+		}
+		if spawns[point] {
+			return fmt.Errorf("spawn point %v is used twice", point)
+		}
+		spawns[point] = true
+	}
+
+	for _, room := range m.Rooms {
+		for i := 0; i < room.Width; i++ {
+			for j := 0; j < room.Height; j++ {
+				point := structs.GridPoint{X: room.X + i, Y: room.Y + j}
+				if room.IsOpen(point) && !reachable[point] {
+					return fmt.Errorf("room %d can't be reached from the start at %v", room.Id, point)
+				}
+			}
+		}
+	}
+
+	for _, tile := range m.Tiles {
+		if tile.Stairs && !reachable[tile.GridPoint] {
+			return fmt.Errorf("stairs at %v can't be reached from the start", tile.GridPoint)
+		}
+	}
+
+	occupied := make(map[structs.GridPoint]bool)
+	for _, creature := range m.Creatures {
+		point := structs.PointToGridPoint(creature.Position)
+		if !m.creatureCanStand(point, tiles, reachable, spawns, occupied) {
+			return fmt.Errorf("creature %s at %v isn't on its own reachable tile", creature.Name, point)
+		}
+		occupied[point] = true
+	}
+
+	return nil
+}
+
+// Moves any creatures that were placed on top of each other, on a player's spawn point or
+// somewhere that can't be reached to the nearest free tile that can
+func (m *Map) repairCreatures() {
+	tiles := m.tileGrid()
+	reachable := m.reachable(tiles)
+	spawns := make(map[structs.GridPoint]bool)
+	for _, point := range m.SpawnPoints {
+		spawns[point] = true
+	}
+
+	occupied := make(map[structs.GridPoint]bool)
+	var creatures []*structs.Creature
+	for _, creature := range m.Creatures {
+		point := structs.PointToGridPoint(creature.Position)
+		if !m.creatureCanStand(point, tiles, reachable, spawns, occupied) {
+			var ok bool
+			point, ok = m.nearestFreeTile(point, tiles, reachable, spawns, occupied)
+			if !ok {
+				log.Debugf("Nowhere to put creature %s, leaving it out", creature.Name)
+				continue
+			}
+			log.Debugf("Moving creature %s to %v", creature.Name, point)
+			creature.Position = point.ToPixels()
+			creature.Home = point
+		}
+		occupied[point] = true
+		creatures = append(creatures, creature)
+	}
+	m.Creatures = creatures
+}
+
+func (m *Map) creatureCanStand(point structs.GridPoint, tiles [][]*structs.Tile, reachable, spawns, occupied map[structs.GridPoint]bool) bool {
+	return m.inBounds(point) && isWalkable(tiles[point.X][point.Y]) && reachable[point] && !spawns[point] && !occupied[point]
+}
+
+// Searches outwards from the point for the closest tile a creature can stand on
+func (m *Map) nearestFreeTile(start structs.GridPoint, tiles [][]*structs.Tile, reachable, spawns, occupied map[structs.GridPoint]bool) (structs.GridPoint, bool) {
+	seen := map[structs.GridPoint]bool{start: true}
+	queue := []structs.GridPoint{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if m.creatureCanStand(current, tiles, reachable, spawns, occupied) {
+			return current, true
+		}
+		for _, next := range neighbors(current) {
+			if m.inBounds(next) && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return structs.GridPoint{}, false
+}
+
+// Flood fills out from the start to find every point that can be walked to, counting
+// doors since they can be opened
+func (m *Map) reachable(tiles [][]*structs.Tile) map[structs.GridPoint]bool {
+	reachable := make(map[structs.GridPoint]bool)
+	if !m.inBounds(m.StartLoc) || !isPassable(tiles[m.StartLoc.X][m.StartLoc.Y]) {
+		return reachable
+	}
+
+	reachable[m.StartLoc] = true
+	queue := []structs.GridPoint{m.StartLoc}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range neighbors(current) {
+			if m.inBounds(next) && !reachable[next] && isPassable(tiles[next.X][next.Y]) {
+				reachable[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reachable
+}
+
+// Returns the map's tiles in a grid indexed by X and Y
+func (m *Map) tileGrid() [][]*structs.Tile {
+	tiles := make([][]*structs.Tile, m.Width)
+	for i := range tiles {
+		tiles[i] = make([]*structs.Tile, m.Height)
+	}
+	for _, tile := range m.Tiles {
+		if m.inBounds(tile.GridPoint) && tiles[tile.X][tile.Y] == nil {
+			tiles[tile.X][tile.Y] = tile
+		}
+	}
+	return tiles
+}
+
+func (m *Map) inBounds(point structs.GridPoint) bool {
+	return point.X >= 0 && point.X < m.Width && point.Y >= 0 && point.Y < m.Height
+}
+
+func isWalkable(tile *structs.Tile) bool {
+	return tile != nil && tile.Walkable
+}
+
+// Returns whether the tile can be walked on, or can be opened up so it can be
+func isPassable(tile *structs.Tile) bool {
+	return isWalkable(tile) || (tile != nil && tile.Toggle != "" && structs.GetTileData(tile.Toggle).Walkable)
+}
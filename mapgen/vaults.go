@@ -0,0 +1,77 @@
+package mapgen
+
+import (
+	"math/rand"
+
+	"github.com/kyhavlov/go-dnd/structs"
+)
+
+// The characters used in prefab room shapes
+const (
+	floorCell = '.'
+	solidCell = '#'
+	torchCell = 'T'
+)
+
+// Prefab vaults that get stitched together by the vault generator. The outer ring of
+// every vault has to be open, since hallways can join onto a room anywhere along its edge.
+var vaults = [][]string{
+	{
+		".......",
+		".#...#.",
+		"...T...",
+		".#...#.",
+		".......",
+	},
+	{
+		".........",
+		".##...##.",
+		".#.....#.",
+		"....T....",
+		".#.....#.",
+		".##...##.",
+		".........",
+	},
+	{
+		".........",
+		".#######.",
+		".#..T..#.",
+		".#.....#.",
+		".###.###.",
+		".........",
+	},
+	{
+		"...T...",
+		".#.#.#.",
+		".......",
+		".#.#.#.",
+		".......",
+		".#.#.#.",
+		".......",
+	},
+	{
+		"..T..",
+		".###.",
+		".#.#.",
+		".#.#.",
+		".#.#.",
+		".#.#.",
+		".....",
+	},
+}
+
+// VaultGenerator grows the dungeon the same way as the rooms generator, but every
+// room after the first is one of the prefab vaults.
+type VaultGenerator struct {
+	Params Params
+}
+
+func (g *VaultGenerator) Generate(random *rand.Rand, floor int) *Map {
+	rooms, start, hallways := growDungeon(random, g.Params.length(random), func(id int, edgeRoom *RoomNode) (*RoomNode, []structs.GridPoint) {
+		shape := vaults[random.Intn(len(vaults))]
+		room, hallway := addNewRoom(id, random, edgeRoom, len(shape[0]), len(shape))
+		room.Shape = shape
+		return room, hallway
+	})
+	return buildLevel(random, rooms, start, hallways, floor)
+}
@@ -0,0 +1,157 @@
+package structs
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// Rarity is a tier of item, which decides how many affixes get rolled onto it
+type Rarity struct {
+	Name string `hcl:",key"`
+
+	// How likely items are to be this rarity compared to the others
+	Weight int
+
+	// How many prefixes and suffixes items of this rarity get
+	Prefixes int
+	Suffixes int
+}
+
+// Affix is a modifier that can be rolled onto an item, adding to its name and stats
+type Affix struct {
+	Name string `hcl:",key"`
+
+	// Either "prefix" or "suffix", which decides where the affix goes in the item's name
+	Kind string
+
+	// How likely the affix is to be picked compared to the others of its kind
+	Weight int
+
+	// The item slots the affix can be rolled on, or any slot if left empty
+	Slots []string
+
+	Skills         []string
+	Requirements   StatComponent `hcl:"reqs"`
+	Bonuses        StatComponent `hcl:"bonus"`
+	PercentBonuses StatComponent `hcl:"percent_bonus"`
+}
+
+const (
+	PrefixAffix = "prefix"
+	SuffixAffix = "suffix"
+)
+
+// Returns whether the affix can be rolled on an item in the given slot
+func (a Affix) FitsSlot(slot string) bool {
+	if len(a.Slots) == 0 {
+		return true
+	}
+	for _, s := range a.Slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// RollItem makes an item of the given type with a random rarity and affixes. random should be
+// seeded the same way on every client so everyone gets the same item.
+func RollItem(name string, coords GridPoint, random *rand.Rand) *Item {
+	item := NewItem(name, coords)
+	if item.IsConsumable() {
+		return item
+	}
+
+	var table SpawnTable
+	for _, rarity := range rarities {
+		table = append(table, SpawnEntry{Name: rarity.Name, Weight: rarity.Weight})
+	}
+	rarity := GetRarity(table.Roll(random))
+	item.Rarity = rarity.Name
+
+	for _, kind := range []string{PrefixAffix, SuffixAffix} {
+		count := rarity.Prefixes
+		if kind == SuffixAffix {
+			count = rarity.Suffixes
+		}
+		for i := 0; i < count; i++ {
+			var choices SpawnTable
+			for _, affix := range affixes {
+				if affix.Kind == kind && affix.FitsSlot(item.Slot) && !item.HasAffix(affix.Name) {
+					choices = append(choices, SpawnEntry{Name: affix.Name, Weight: affix.Weight})
+				}
+			}
+			if len(choices) == 0 {
+				break
+			}
+			item.ApplyAffix(GetAffix(choices.Roll(random)))
+		}
+	}
+
+	return item
+}
+
+// ApplyAffix adds the affix's stats and skills onto this item
+func (item *Item) ApplyAffix(affix Affix) {
+	item.Affixes = append(item.Affixes, affix.Name)
+	item.Requirements = item.Requirements.Plus(affix.Requirements)
+	item.Bonuses = item.Bonuses.Plus(affix.Bonuses)
+	item.PercentBonuses = item.PercentBonuses.Plus(affix.PercentBonuses)
+
+	// Copy the skills so we don't change the ones shared with the item's template
+	skills := append([]string{}, item.Skills...)
+	for _, skill := range affix.Skills {
+		if !item.HasSkill(skill) {
+			skills = append(skills, skill)
+		}
+	}
+	item.Skills = skills
+}
+
+// HasAffix returns whether the named affix has been rolled onto this item
+func (item *Item) HasAffix(name string) bool {
+	for _, affix := range item.Affixes {
+		if affix == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSkill returns whether the item grants the named skill
+func (item *Item) HasSkill(name string) bool {
+	for _, skill := range item.Skills {
+		if skill == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DisplayName returns the item's name with its prefixes in front and suffixes after, like
+// "Sturdy Leather Armor of the Bear"
+func (item *Item) DisplayName() string {
+	var prefixes, suffixes []string
+	for _, name := range item.Affixes {
+		if GetAffix(name).Kind == PrefixAffix {
+			prefixes = append(prefixes, name)
+		} else {
+			suffixes = append(suffixes, name)
+		}
+	}
+	return strings.Join(append(append(prefixes, item.Name), suffixes...), " ")
+}
+
+// Plus returns the sum of two sets of stats
+func (s StatComponent) Plus(other StatComponent) StatComponent {
+	return StatComponent{
+		Movement:     s.Movement + other.Movement,
+		MaxLife:      s.MaxLife + other.MaxLife,
+		Strength:     s.Strength + other.Strength,
+		Dexterity:    s.Dexterity + other.Dexterity,
+		Intelligence: s.Intelligence + other.Intelligence,
+		MaxStamina:   s.MaxStamina + other.MaxStamina,
+		Stamina:      s.Stamina + other.Stamina,
+		StaminaRegen: s.StaminaRegen + other.StaminaRegen,
+	}
+}
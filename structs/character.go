@@ -0,0 +1,337 @@
+package structs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/engoengine/math/imath"
+)
+
+// The directory character saves are kept in
+const SaveDir = "saves"
+
+var characterNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,24}$`)
+
+// CharacterSave is everything about a player's character that carries over between games.
+// Stats and skills aren't saved directly; they're rebuilt from the class, experience and spent
+// stat points, so they always match the current data file.
+type CharacterSave struct {
+	Name  string `json:"name"`
+	Class string `json:"class"`
+
+	// The version of the data file the character was saved with
+	DataVersion int `json:"data_version"`
+
+	Experience  int            `json:"experience"`
+	SpentPoints map[string]int `json:"spent_points,omitempty"`
+
+	Life    int `json:"life"`
+	Stamina int `json:"stamina"`
+
+	// Indexed by slot, with an empty name for an empty slot
+	Equipment []SavedItem `json:"equipment"`
+	Inventory []SavedItem `json:"inventory"`
+}
+
+// SavedItem is an item a saved character is carrying
+type SavedItem struct {
+	Name    string   `json:"name,omitempty"`
+	Rarity  string   `json:"rarity,omitempty"`
+	Affixes []string `json:"affixes,omitempty"`
+	Charges int      `json:"charges,omitempty"`
+	Count   int      `json:"count,omitempty"`
+}
+
+// ValidCharacterName returns an error if the name can't be used to save a character under
+func ValidCharacterName(name string) error {
+	if !characterNamePattern.MatchString(name) {
+		return fmt.Errorf("Character names need 1-24 letters, numbers, '-' or '_', not '%s'", name)
+	}
+	return nil
+}
+
+// NewCharacterSave records the player's character so it can be saved
+func NewCharacterSave(c *Creature) *CharacterSave {
+	save := &CharacterSave{
+		Name:        c.Character,
+		Class:       c.Class,
+		DataVersion: dataVersion,
+		Experience:  c.Experience,
+		SpentPoints: make(map[string]int),
+		Life:        c.Life,
+		Stamina:     c.Stamina,
+	}
+
+	// Whatever the level bonuses have beyond the class's level_up stats was bought with points
+	levels := c.Level - 1
+	save.SpentPoints[StrengthStat] = c.LevelBonuses.Strength - levels*c.LevelUp.Strength
+	save.SpentPoints[DexterityStat] = c.LevelBonuses.Dexterity - levels*c.LevelUp.Dexterity
+	save.SpentPoints[IntelligenceStat] = c.LevelBonuses.Intelligence - levels*c.LevelUp.Intelligence
+
+	for _, item := range c.Equipment {
+		save.Equipment = append(save.Equipment, newSavedItem(item))
+	}
+	for _, item := range c.Inventory {
+		save.Inventory = append(save.Inventory, newSavedItem(item))
+	}
+	return save
+}
+
+func newSavedItem(item *Item) SavedItem {
+	if item == nil {
+		return SavedItem{}
+	}
+	return SavedItem{
+		Name:    item.Name,
+		Rarity:  item.Rarity,
+		Affixes: item.Affixes,
+		Charges: item.Charges,
+		Count:   item.Count,
+	}
+}
+
+// SaveCharacter writes the player's character to its save file in the given directory
+func SaveCharacter(dir string, c *Creature) error {
+	if err := ValidCharacterName(c.Character); err != nil {
+		return err
+	}
+	bytes, err := json.MarshalIndent(NewCharacterSave(c), "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding character '%s': %s", c.Character, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error making save directory: %s", err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, c.Character+".json"), bytes, 0644)
+}
+
+// LoadCharacter reads the named character's save file from the given directory. Returns nil
+// with no error if the character hasn't been saved yet.
+func LoadCharacter(dir string, name string) (*CharacterSave, error) {
+	if err := ValidCharacterName(name); err != nil {
+		return nil, err
+	}
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading save for '%s': %s", name, err)
+	}
+
+	save := &CharacterSave{}
+	if err := json.Unmarshal(bytes, save); err != nil {
+		return nil, fmt.Errorf("Error parsing save for '%s': %s", name, err)
+	}
+	if save.Name != name {
+		return nil, fmt.Errorf("Save file for '%s' has a character named '%s'", name, save.Name)
+	}
+	return save, nil
+}
+
+// Upgrade updates a character saved with an older version of the data file, taking away any
+// items that no longer exist or fit where they are, and refunding spent stat points if they
+// no longer add up. Returns a description of everything that was changed.
+func (save *CharacterSave) Upgrade() []string {
+	if save.DataVersion >= dataVersion {
+		return nil
+	}
+
+	var changes []string
+	for slot, saved := range save.Equipment {
+		if saved.Name == "" {
+			continue
+		}
+		if err := saved.validate(slot); err != nil {
+			changes = append(changes, err.Error())
+			save.Equipment[slot] = SavedItem{}
+		}
+	}
+	for slot, saved := range save.Inventory {
+		if saved.Name == "" {
+			continue
+		}
+		if err := saved.validate(-1); err != nil {
+			changes = append(changes, err.Error())
+			save.Inventory[slot] = SavedItem{}
+		}
+	}
+	if err := save.validatePoints(); err != nil {
+		changes = append(changes, err.Error()+", refunding stat points")
+		save.SpentPoints = nil
+	}
+
+	save.DataVersion = dataVersion
+	return changes
+}
+
+// Validate returns an error if the save doesn't describe a character that could exist with the
+// current data file, like one carrying items that don't exist. Saves from older data versions
+// should be upgraded first.
+func (save *CharacterSave) Validate() error {
+	if err := ValidCharacterName(save.Name); err != nil {
+		return err
+	}
+	if save.DataVersion != dataVersion {
+		return fmt.Errorf("'%s' was saved with data version %d, not %d", save.Name, save.DataVersion, dataVersion)
+	}
+	if GetClass(save.Class).Name == "" {
+		return fmt.Errorf("'%s' has unrecognized class: '%s'", save.Name, save.Class)
+	}
+	if save.Experience < 0 {
+		return fmt.Errorf("'%s' has negative experience", save.Name)
+	}
+	if err := save.validatePoints(); err != nil {
+		return err
+	}
+
+	if len(save.Equipment) > EquipmentSlots || len(save.Inventory) > MaxInventorySize {
+		return fmt.Errorf("'%s' has too many item slots", save.Name)
+	}
+	for slot, saved := range save.Equipment {
+		if saved.Name == "" {
+			continue
+		}
+		if err := saved.validate(slot); err != nil {
+			return err
+		}
+	}
+	for _, saved := range save.Inventory {
+		if saved.Name == "" {
+			continue
+		}
+		if err := saved.validate(-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns an error if the save has spent more stat points than its level gives
+func (save *CharacterSave) validatePoints() error {
+	spent := 0
+	for stat, points := range save.SpentPoints {
+		switch stat {
+		case StrengthStat, DexterityStat, IntelligenceStat:
+		default:
+			return fmt.Errorf("'%s' has points in unrecognized stat: '%s'", save.Name, stat)
+		}
+		if points < 0 {
+			return fmt.Errorf("'%s' has negative points in %s", save.Name, stat)
+		}
+		spent += points
+	}
+	if earned := StatPointsPerLevel * (levelForXP(save.Experience) - 1); spent > earned {
+		return fmt.Errorf("'%s' has spent %d stat points but only earned %d", save.Name, spent, earned)
+	}
+	return nil
+}
+
+// Returns an error if the item couldn't exist in the given equipment slot, or in the
+// inventory if slot is -1
+func (saved SavedItem) validate(slot int) error {
+	data, ok := itemData[saved.Name]
+	if !ok {
+		return fmt.Errorf("Unrecognized item: '%s'", saved.Name)
+	}
+	if slot != -1 && (data.IsConsumable() || int(data.Type) != slot) {
+		return fmt.Errorf("%s can't be equipped in slot %d", saved.Name, slot)
+	}
+	rarity := GetRarity(saved.Rarity)
+	if saved.Rarity != "" && rarity.Name == "" {
+		return fmt.Errorf("%s has unrecognized rarity: '%s'", saved.Name, saved.Rarity)
+	}
+	if data.IsConsumable() && len(saved.Affixes) > 0 {
+		return fmt.Errorf("%s can't have affixes", saved.Name)
+	}
+
+	// Only allow the affixes RollItem could have given an item of this rarity
+	var prefixes, suffixes int
+	for i, name := range saved.Affixes {
+		affix := GetAffix(name)
+		if affix.Name == "" || !affix.FitsSlot(data.Slot) {
+			return fmt.Errorf("%s has unrecognized affix: '%s'", saved.Name, name)
+		}
+		for _, other := range saved.Affixes[:i] {
+			if other == name {
+				return fmt.Errorf("%s has affix '%s' more than once", saved.Name, name)
+			}
+		}
+		if affix.Kind == PrefixAffix {
+			prefixes++
+		} else {
+			suffixes++
+		}
+	}
+	if prefixes > rarity.Prefixes || suffixes > rarity.Suffixes {
+		return fmt.Errorf("%s has too many affixes for its rarity: %v", saved.Name, saved.Affixes)
+	}
+	if saved.Charges < 0 || saved.Charges > data.MaxCharges() {
+		return fmt.Errorf("%s has %d charges", saved.Name, saved.Charges)
+	}
+	if saved.Count < 1 || saved.Count > imath.Max(1, data.StackSize) {
+		return fmt.Errorf("%s has a stack of %d", saved.Name, saved.Count)
+	}
+	return nil
+}
+
+// Character makes the saved character as a new player at the given location, or returns an
+// error if the save isn't valid
+func (save *CharacterSave) Character(coords GridPoint) (*Creature, error) {
+	if err := save.Validate(); err != nil {
+		return nil, err
+	}
+
+	player := NewPlayer(save.Class, coords)
+	player.Character = save.Name
+	player.StartingItems = nil
+	player.GainXP(save.Experience)
+	for _, stat := range []string{StrengthStat, DexterityStat, IntelligenceStat} {
+		for i := 0; i < save.SpentPoints[stat]; i++ {
+			player.SpendStatPoint(stat)
+		}
+	}
+
+	for slot, saved := range save.Equipment {
+		if saved.Name != "" {
+			player.Equipment[slot] = saved.item(coords)
+		}
+	}
+	for slot, saved := range save.Inventory {
+		if saved.Name != "" {
+			player.SetInventoryItem(slot, saved.item(coords))
+		}
+	}
+
+	player.InvalidateStats()
+	stats := player.Stats()
+	player.Life = imath.Max(1, imath.Min(save.Life, stats.MaxLife))
+	player.Stamina = imath.Max(0, imath.Min(save.Stamina, stats.MaxStamina))
+	return player, nil
+}
+
+// Makes the saved item, already being carried
+func (saved SavedItem) item(coords GridPoint) *Item {
+	item := NewItem(saved.Name, coords)
+	item.OnGround = false
+	item.RenderComponent.Hidden = true
+	item.Rarity = saved.Rarity
+	for _, name := range saved.Affixes {
+		item.ApplyAffix(GetAffix(name))
+	}
+	item.Charges = saved.Charges
+	item.Count = saved.Count
+	return item
+}
+
+// Returns the level a creature with the given experience is at
+func levelForXP(xp int) int {
+	level := 1
+	for xp >= XPForLevel(level+1) {
+		level++
+	}
+	return level
+}
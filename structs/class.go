@@ -0,0 +1,59 @@
+package structs
+
+// Class is a kind of character a player can pick before the game starts, with its own stats,
+// starting items and skills
+type Class struct {
+	Name string `hcl:",key"`
+	Icon int    `hcl:"icon"`
+
+	Stats   StatComponent `hcl:"stats"`
+	LevelUp StatComponent `hcl:"level_up"`
+
+	Items  []string
+	Skills []string
+
+	// Skills learned on reaching later levels
+	Unlocks []SkillUnlock `hcl:"unlock"`
+}
+
+// SkillUnlock is a skill a class learns once it reaches the given level
+type SkillUnlock struct {
+	Skill string `hcl:",key"`
+	Level int
+}
+
+// SkillsAt returns the skills the class learns on reaching the given level
+func (class Class) SkillsAt(level int) []string {
+	var skills []string
+	for _, unlock := range class.Unlocks {
+		if unlock.Level == level {
+			skills = append(skills, unlock.Skill)
+		}
+	}
+	return skills
+}
+
+// NewPlayer makes a player character of the named class, starting from the "Player" creature
+// in the data file. Unknown classes fall back to the first class in the data file.
+func NewPlayer(className string, coords GridPoint) *Creature {
+	class := GetClass(className)
+	if class.Name == "" && len(classes) > 0 {
+		class = classes[0]
+	}
+
+	player := NewCreature("Player", coords)
+	player.IsPlayerTeam = true
+	player.Class = class.Name
+	if class.Icon != 0 {
+		player.Icon = class.Icon
+		player.Drawable = spriteCell(class.Icon)
+	}
+	player.StatComponent = class.Stats
+	player.Life = player.MaxLife
+	player.Stamina = player.MaxStamina
+	player.LevelUp = class.LevelUp
+	player.StartingItems = append(append([]string{}, player.StartingItems...), class.Items...)
+	player.InnateSkills = append(player.InnateSkills, class.Skills...)
+	player.InnateSkills = append(player.InnateSkills, class.SkillsAt(1)...)
+	return player
+}
@@ -0,0 +1,68 @@
+package structs
+
+// UseComponent describes what a consumable item does when it's used
+type UseComponent struct {
+	// Life and stamina restored to the user
+	Heal    int
+	Stamina int
+
+	// A status put on the user
+	Status StatusEffect
+
+	// Whether the item moves the user to the target location, and how far away that can be
+	Teleport bool
+	Range    int
+
+	// A skill cast at the target, which doesn't cost any stamina
+	Skill string
+}
+
+// Returns whether using the item needs a target location
+func (u UseComponent) IsTargeted() bool {
+	return u.Teleport || u.Skill != ""
+}
+
+// StatusEffect is a temporary change to a creature's stats, like from a potion
+type StatusEffect struct {
+	Name string
+
+	// How many of the creature's turns the status lasts for
+	Turns int
+
+	Bonuses        StatComponent `hcl:"bonus"`
+	PercentBonuses StatComponent `hcl:"percent_bonus"`
+}
+
+// IsConsumable returns whether the item gets used up instead of being equipped
+func (item *Item) IsConsumable() bool {
+	return item.Type == Consumable
+}
+
+// AddStatus puts a status on the creature, replacing any existing status with the same name
+func (c *Creature) AddStatus(status StatusEffect) {
+	for i, existing := range c.Statuses {
+		if existing.Name == status.Name {
+			c.Statuses[i] = status
+			c.InvalidateStats()
+			return
+		}
+	}
+	c.Statuses = append(c.Statuses, status)
+	c.InvalidateStats()
+}
+
+// TickStatuses counts down the creature's statuses at the start of its turn, removing any
+// that have run out
+func (c *Creature) TickStatuses() {
+	var statuses []StatusEffect
+	for _, status := range c.Statuses {
+		status.Turns--
+		if status.Turns > 0 {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) != len(c.Statuses) {
+		c.InvalidateStats()
+	}
+	c.Statuses = statuses
+}
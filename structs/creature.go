@@ -1,6 +1,8 @@
 package structs
 
 import (
+	"fmt"
+
 	"engo.io/ecs"
 	"engo.io/engo"
 	"engo.io/engo/common"
@@ -8,6 +10,20 @@ import (
 
 const LifeIcon = 25
 
+// AwarenessState tracks what an enemy creature knows about the players
+type AwarenessState int
+
+const (
+	// Asleep or wandering, hasn't noticed anything
+	Idle AwarenessState = iota
+	// Heard something and is going to investigate
+	Suspicious
+	// Has seen a player and is chasing them
+	Hunting
+	// Lost track of the players and is going back to where it started
+	Returning
+)
+
 type Creature struct {
 	ecs.BasicEntity        `hcl:"-"`
 	NetworkID              `hcl:"-"`
@@ -23,23 +39,72 @@ type Creature struct {
 	Icon int    `hcl:"icon"`
 
 	StatComponent `hcl:"stats"`
+	Light         LightComponent `hcl:"light"`
 
 	StartingItems []string `hcl:"items"`
 
-	Equipment    [EquipmentSlots]*Item
-	Inventory    [InventorySize]*Item
+	Equipment [EquipmentSlots]*Item
+	Inventory []*Item `hcl:"-"`
+
+	// How many inventory slots the creature has before counting its equipment, 0 to use the default
+	InventorySlots int `hcl:"inventory_slots"`
+
 	InnateSkills []string `hcl:"skills"`
 	Skills       []string `hcl:"-"`
 
+	// The name of the AI behavior profile that controls this creature
+	Behavior string `hcl:"behavior"`
+
+	// How many tiles away the creature can see, 0 to use the default
+	SightRange int `hcl:"sight"`
+
+	// Bosses get placed in the deepest room of the dungeon, and change their behavior
+	// as they go through phases of the fight
+	Boss   bool
+	Phases []BossPhase `hcl:"phase"`
+	Phase  int         `hcl:"-"`
+
+	// Temporary changes to the creature's stats, like from potions
+	Statuses []StatusEffect `hcl:"-"`
+
+	// Experience given to the players for killing the creature
+	XP int `hcl:"xp"`
+
+	// The class a player picked, which decides the skills they learn as they level up, and the
+	// name their character is saved under
+	Class     string `hcl:"-"`
+	Character string `hcl:"-"`
+
+	// The creature's progress towards its next level, and the stats it gains at each level
+	Level        int           `hcl:"-"`
+	Experience   int           `hcl:"-"`
+	StatPoints   int           `hcl:"-"`
+	LevelUp      StatComponent `hcl:"level_up"`
+	LevelBonuses StatComponent `hcl:"-"`
+
+	// The bonuses from equipment, statuses, levels and boss phases, cached by Stats
+	modifiers   statModifiers
+	statsCached bool
+
+	// The loot table rolled for what the creature drops when it dies, on top of what it carries
+	LootTable string `hcl:"loot_table"`
+
 	IsPlayerTeam bool
-	IsActivated  bool
+
+	Awareness AwarenessState `hcl:"-"`
+	// Where the creature last saw or heard a player
+	LastKnownLoc GridPoint `hcl:"-"`
+	// Where the creature was spawned, which it goes back to after losing track of the players
+	Home GridPoint `hcl:"-"`
 }
 
 func NewCreature(name string, coords GridPoint) *Creature {
 	creature := GetCreatureData(name)
 	creature.Life = creature.MaxLife
 	creature.Stamina = creature.MaxStamina
+	creature.Level = 1
 	creature.InnateSkills = append([]string{"Basic Attack"}, creature.InnateSkills...)
+	creature.Home = coords
 
 	creature.BasicEntity = ecs.NewBasic()
 	creature.LifeIcon = ecs.NewBasic()
@@ -50,7 +115,7 @@ func NewCreature(name string, coords GridPoint) *Creature {
 		Height:   TileWidth,
 	}
 	creature.RenderComponent = common.RenderComponent{
-		Drawable: Sprites.Cell(creature.Icon),
+		Drawable: spriteCell(creature.Icon),
 		Scale:    engo.Point{1, 1},
 	}
 	creature.RenderComponent.SetZIndex(1)
@@ -58,6 +123,30 @@ func NewCreature(name string, coords GridPoint) *Creature {
 	return &creature
 }
 
+// BossPhase is a stage of a boss fight, which starts once the boss's life drops to
+// LifePercent of its max life
+type BossPhase struct {
+	Name        string `hcl:",key"`
+	LifePercent int    `hcl:"life_percent"`
+
+	// The behavior profile to switch to, if set
+	Behavior string
+
+	// Skills learned and stats gained when the phase starts
+	Skills  []string
+	Bonuses StatComponent `hcl:"bonus"`
+
+	// Creatures summoned around the boss when the phase starts
+	Summons []string
+}
+
+// Phases sorts boss phases so the ones with the highest life threshold come first
+type Phases []BossPhase
+
+func (p Phases) Len() int           { return len(p) }
+func (p Phases) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p Phases) Less(i, j int) bool { return p[i].LifePercent > p[j].LifePercent }
+
 func (c *Creature) GetSkills() []string {
 	var skills []string
 	skills = append(skills, c.InnateSkills...)
@@ -70,56 +159,6 @@ func (c *Creature) GetSkills() []string {
 	return skills
 }
 
-func (c *Creature) GetEffectiveMovement() int {
-	life := c.Movement
-	for _, item := range c.Equipment {
-		if item != nil {
-			life += item.Bonuses.Movement
-		}
-	}
-	return life
-}
-
-func (c *Creature) GetEffectiveMaxLife() int {
-	life := c.MaxLife
-	for _, item := range c.Equipment {
-		if item != nil {
-			life += item.Bonuses.MaxLife
-		}
-	}
-	return life
-}
-
-func (c *Creature) GetEffectiveStrength() int {
-	str := c.Strength
-	for _, item := range c.Equipment {
-		if item != nil {
-			str += item.Bonuses.Strength
-		}
-	}
-	return str
-}
-
-func (c *Creature) GetEffectiveDexterity() int {
-	dex := c.Dexterity
-	for _, item := range c.Equipment {
-		if item != nil {
-			dex += item.Bonuses.Dexterity
-		}
-	}
-	return dex
-}
-
-func (c *Creature) GetEffectiveIntelligence() int {
-	intelligence := c.Intelligence
-	for _, item := range c.Equipment {
-		if item != nil {
-			intelligence += item.Bonuses.Intelligence
-		}
-	}
-	return intelligence
-}
-
 func (c *Creature) HasIncreasedMeleeRange() bool {
 	for _, item := range c.Equipment {
 		if item != nil && item.GrantsIncreasedMeleeRange {
@@ -130,16 +169,24 @@ func (c *Creature) HasIncreasedMeleeRange() bool {
 }
 
 func (c *Creature) CanEquipItem(item *Item) bool {
-	if c.GetEffectiveStrength() < item.Requirements.Strength {
-		return false
+	return c.EquipError(item) == nil
+}
+
+// EquipError returns why the creature can't equip the item, or nil if it can
+func (c *Creature) EquipError(item *Item) error {
+	if item.IsConsumable() {
+		return fmt.Errorf("%s can't be equipped", item.DisplayName())
 	}
-	if c.GetEffectiveDexterity() < item.Requirements.Dexterity {
-		return false
+	stats := c.Stats()
+	if stats.Strength < item.Requirements.Strength {
+		return fmt.Errorf("%s needs %d strength", item.DisplayName(), item.Requirements.Strength)
 	}
-	if c.GetEffectiveIntelligence() < item.Requirements.Intelligence {
-		return false
+	if stats.Dexterity < item.Requirements.Dexterity {
+		return fmt.Errorf("%s needs %d dexterity", item.DisplayName(), item.Requirements.Dexterity)
+	}
+	if stats.Intelligence < item.Requirements.Intelligence {
+		return fmt.Errorf("%s needs %d intelligence", item.DisplayName(), item.Requirements.Intelligence)
 	}
 
-
-	return true
-}
\ No newline at end of file
+	return nil
+}
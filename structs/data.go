@@ -3,6 +3,7 @@ package structs
 import (
 	"fmt"
 	"io/ioutil"
+	"sort"
 
 	"github.com/hashicorp/hcl"
 )
@@ -11,17 +12,45 @@ var itemData map[string]Item
 var creatureData map[string]Creature
 var tileData map[string]Tile
 var skillData map[string]Skill
+var roomThemes []RoomTheme
+var lootTables map[string]LootTable
+var rarities []Rarity
+var affixes []Affix
+var classes []Class
+var dataVersion int
+
+// The item types for each slot name items can have in the data file
+var itemSlots = map[string]ItemType{
+	"weapon":     Weapon,
+	"off-hand":   OffHand,
+	"armor":      Armor,
+	"helm":       Helm,
+	"accessory":  Accessory,
+	"consumable": Consumable,
+}
 
 type Data struct {
-	Items     []Item     `hcl:"item"`
-	Creatures []Creature `hcl:"creature"`
-	Tiles     []Tile     `hcl:"tile"`
-	Skills    []Skill    `hcl:"skill"`
+	Version int `hcl:"version"`
+
+	Items     []Item      `hcl:"item"`
+	Creatures []Creature  `hcl:"creature"`
+	Tiles     []Tile      `hcl:"tile"`
+	Skills    []Skill     `hcl:"skill"`
+	Themes    []RoomTheme `hcl:"theme"`
+	Loot      []LootTable `hcl:"loot_table"`
+	Rarities  []Rarity    `hcl:"rarity"`
+	Affixes   []Affix     `hcl:"affix"`
+	Classes   []Class     `hcl:"class"`
 }
 
 func LoadItems() error {
+	return LoadDataFile("data.hcl")
+}
+
+// LoadDataFile loads the game data from the hcl file at the given path
+func LoadDataFile(path string) error {
 	// Read the file contents
-	bytes, err := ioutil.ReadFile("data.hcl")
+	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("Error loading config file: %s", err)
 	}
@@ -31,6 +60,8 @@ func LoadItems() error {
 		return err
 	}
 
+	dataVersion = data.Version
+
 	skillData = make(map[string]Skill)
 	for _, skill := range data.Skills {
 		if _, ok := skillData[skill.Name]; ok {
@@ -50,30 +81,135 @@ func LoadItems() error {
 				return fmt.Errorf("Error: item '%s' has unrecognized skill: '%s'", item.Name, skill)
 			}
 		}
-		switch item.Slot {
-		case "weapon":
-			item.Type = Weapon
-		case "off-hand":
-			item.Type = OffHand
-		case "armor":
-			item.Type = Armor
-		case "helm":
-			item.Type = Helm
-		case "accessory":
-			item.Type = Accessory
+		itemType, ok := itemSlots[item.Slot]
+		if !ok {
+			return fmt.Errorf("Error: item '%s' has unrecognized slot: '%s'", item.Name, item.Slot)
+		}
+		item.Type = itemType
+		if _, ok := skillData[item.Use.Skill]; item.Use.Skill != "" && !ok {
+			return fmt.Errorf("Error: item '%s' has unrecognized use skill: '%s'", item.Name, item.Use.Skill)
 		}
 		itemData[item.Name] = item
 	}
 
+	rarities = nil
+	for _, rarity := range data.Rarities {
+		if GetRarity(rarity.Name).Name != "" {
+			return fmt.Errorf("Error: got multiple sets of stats for rarity: '%s'", rarity.Name)
+		}
+		if rarity.Weight <= 0 {
+			return fmt.Errorf("Error: rarity '%s' needs a weight above 0", rarity.Name)
+		}
+		rarities = append(rarities, rarity)
+	}
+
+	affixes = nil
+	for _, affix := range data.Affixes {
+		if GetAffix(affix.Name).Name != "" {
+			return fmt.Errorf("Error: got multiple sets of stats for affix: '%s'", affix.Name)
+		}
+		if affix.Kind != PrefixAffix && affix.Kind != SuffixAffix {
+			return fmt.Errorf("Error: affix '%s' should be a prefix or suffix, not '%s'", affix.Name, affix.Kind)
+		}
+		if affix.Weight <= 0 {
+			return fmt.Errorf("Error: affix '%s' needs a weight above 0", affix.Name)
+		}
+		for _, skill := range affix.Skills {
+			if _, ok := skillData[skill]; !ok {
+				return fmt.Errorf("Error: affix '%s' has unrecognized skill: '%s'", affix.Name, skill)
+			}
+		}
+		for _, slot := range affix.Slots {
+			if itemType, ok := itemSlots[slot]; !ok || itemType == Consumable {
+				return fmt.Errorf("Error: affix '%s' has unrecognized equipment slot: '%s'", affix.Name, slot)
+			}
+		}
+		affixes = append(affixes, affix)
+	}
+
+	lootTables = make(map[string]LootTable)
+	for _, table := range data.Loot {
+		if _, ok := lootTables[table.Name]; ok {
+			return fmt.Errorf("Error: got multiple sets of items for loot table: '%s'", table.Name)
+		}
+		for _, entry := range table.Items {
+			if _, ok := itemData[entry.Name]; !ok || entry.Weight <= 0 {
+				return fmt.Errorf("Error: loot table '%s' has unrecognized item or bad weight: '%s'", table.Name, entry.Name)
+			}
+		}
+		for _, item := range table.Always {
+			if _, ok := itemData[item]; !ok {
+				return fmt.Errorf("Error: loot table '%s' has unrecognized item: '%s'", table.Name, item)
+			}
+		}
+		lootTables[table.Name] = table
+	}
+
 	creatureData = make(map[string]Creature)
 	for _, creature := range data.Creatures {
 		if _, ok := creatureData[creature.Name]; ok {
 			return fmt.Errorf("Error: got multiple sets of stats for creature: '%s'", creature.Name)
 		}
+		if _, ok := lootTables[creature.LootTable]; creature.LootTable != "" && !ok {
+			return fmt.Errorf("Error: creature '%s' has unrecognized loot table: '%s'", creature.Name, creature.LootTable)
+		}
+		for _, item := range creature.StartingItems {
+			if _, ok := itemData[item]; !ok {
+				return fmt.Errorf("Error: creature '%s' has unrecognized starting item: '%s'", creature.Name, item)
+			}
+		}
+		for _, phase := range creature.Phases {
+			for _, skill := range phase.Skills {
+				if _, ok := skillData[skill]; !ok {
+					return fmt.Errorf("Error: creature '%s' phase '%s' has unrecognized skill: '%s'", creature.Name, phase.Name, skill)
+				}
+			}
+		}
+		sort.Stable(Phases(creature.Phases))
 
 		creatureData[creature.Name] = creature
 	}
 
+	classes = nil
+	for _, class := range data.Classes {
+		if GetClass(class.Name).Name != "" {
+			return fmt.Errorf("Error: got multiple sets of stats for class: '%s'", class.Name)
+		}
+		if class.Stats.MaxLife <= 0 {
+			return fmt.Errorf("Error: class '%s' needs a stats block with some life", class.Name)
+		}
+		for _, item := range class.Items {
+			if _, ok := itemData[item]; !ok {
+				return fmt.Errorf("Error: class '%s' has unrecognized starting item: '%s'", class.Name, item)
+			}
+		}
+		for _, skill := range class.Skills {
+			if _, ok := skillData[skill]; !ok {
+				return fmt.Errorf("Error: class '%s' has unrecognized skill: '%s'", class.Name, skill)
+			}
+		}
+		for _, unlock := range class.Unlocks {
+			if _, ok := skillData[unlock.Skill]; !ok {
+				return fmt.Errorf("Error: class '%s' unlocks unrecognized skill: '%s'", class.Name, unlock.Skill)
+			}
+			if unlock.Level < 1 {
+				return fmt.Errorf("Error: class '%s' unlocks skill '%s' at bad level: %d", class.Name, unlock.Skill, unlock.Level)
+			}
+		}
+		classes = append(classes, class)
+	}
+
+	// Check summons once all the creatures are loaded, since they can refer to each other
+	for _, creature := range creatureData {
+		for _, phase := range creature.Phases {
+			for _, summon := range phase.Summons {
+				if _, ok := creatureData[summon]; !ok {
+					return fmt.Errorf("Error: creature '%s' phase '%s' has unrecognized summon: '%s'", creature.Name, phase.Name, summon)
+				}
+			}
+		}
+	}
+
 	tileData = make(map[string]Tile)
 	for _, tile := range data.Tiles {
 		if _, ok := tileData[tile.Name]; ok {
@@ -83,6 +219,43 @@ func LoadItems() error {
 		tileData[tile.Name] = tile
 	}
 
+	for _, tile := range tileData {
+		if _, ok := tileData[tile.Toggle]; tile.Toggle != "" && !ok {
+			return fmt.Errorf("Error: tile '%s' has unrecognized toggle: '%s'", tile.Name, tile.Toggle)
+		}
+		if _, ok := tileData[tile.Hazard.Disguise]; tile.Hazard.Disguise != "" && !ok {
+			return fmt.Errorf("Error: tile '%s' has unrecognized disguise: '%s'", tile.Name, tile.Hazard.Disguise)
+		}
+	}
+
+	roomThemes = nil
+	themeNames := make(map[string]bool)
+	for _, theme := range data.Themes {
+		if themeNames[theme.Name] {
+			return fmt.Errorf("Error: got multiple sets of spawns for room theme: '%s'", theme.Name)
+		}
+		themeNames[theme.Name] = true
+		if theme.Weight <= 0 {
+			return fmt.Errorf("Error: room theme '%s' needs a weight above 0", theme.Name)
+		}
+		for _, entry := range theme.CreatureTable {
+			if _, ok := creatureData[entry.Name]; !ok || entry.Weight <= 0 {
+				return fmt.Errorf("Error: room theme '%s' has unrecognized creature or bad weight: '%s'", theme.Name, entry.Name)
+			}
+		}
+		for _, entry := range theme.ItemTable {
+			if _, ok := itemData[entry.Name]; !ok || entry.Weight <= 0 {
+				return fmt.Errorf("Error: room theme '%s' has unrecognized item or bad weight: '%s'", theme.Name, entry.Name)
+			}
+		}
+		for _, entry := range theme.HazardTable {
+			if tile, ok := tileData[entry.Name]; !ok || !tile.Walkable || entry.Weight <= 0 {
+				return fmt.Errorf("Error: room theme '%s' has unrecognized or unwalkable hazard or bad weight: '%s'", theme.Name, entry.Name)
+			}
+		}
+		roomThemes = append(roomThemes, theme)
+	}
+
 	return nil
 }
 
@@ -103,10 +276,71 @@ func GetCreatureData(name string) Creature {
 	return creatureData[name]
 }
 
+// Returns the names of all the boss creatures, in sorted order
+func GetBossNames() []string {
+	var bosses []string
+	for name, creature := range creatureData {
+		if creature.Boss {
+			bosses = append(bosses, name)
+		}
+	}
+	sort.Strings(bosses)
+	return bosses
+}
+
 func GetTileData(name string) Tile {
 	return tileData[name]
 }
 
+// GetRoomThemes returns the room themes in the order they're listed in the data file
+func GetRoomThemes() []RoomTheme {
+	return roomThemes
+}
+
+// GetRarity returns the named rarity, or an empty one if there isn't one by that name
+func GetRarity(name string) Rarity {
+	for _, rarity := range rarities {
+		if rarity.Name == name {
+			return rarity
+		}
+	}
+	return Rarity{}
+}
+
+// GetAffix returns the named affix, or an empty one if there isn't one by that name
+func GetAffix(name string) Affix {
+	for _, affix := range affixes {
+		if affix.Name == name {
+			return affix
+		}
+	}
+	return Affix{}
+}
+
+// DataVersion returns the version of the loaded data file, which character saves are checked against
+func DataVersion() int {
+	return dataVersion
+}
+
+// GetClass returns the named class, or an empty one if there isn't one by that name
+func GetClass(name string) Class {
+	for _, class := range classes {
+		if class.Name == name {
+			return class
+		}
+	}
+	return Class{}
+}
+
+// GetClasses returns the player classes in the order they're listed in the data file
+func GetClasses() []Class {
+	return classes
+}
+
+func GetLootTable(name string) LootTable {
+	return lootTables[name]
+}
+
 func GetSkillData(name string) Skill {
 	return skillData[name]
 }
@@ -1,10 +1,23 @@
 package structs
 
 import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
 	"reflect"
 	"testing"
+
+	"engo.io/engo"
 )
 
+func TestMain(m *testing.M) {
+	// Creatures send a message when their render component is set up, which needs a mailbox
+	// to go to even without a game window
+	engo.Mailbox = &engo.MessageManager{}
+	os.Exit(m.Run())
+}
+
 func TestParseItem(t *testing.T) {
 	raw := `
 item "Sapphire Staff" {
@@ -25,6 +38,10 @@ item "Sapphire Staff" {
     stamina = 40
     stamina_regen = 3
   }
+  light {
+    brightness = 200
+    radius = 4
+  }
 }`
 
 	expected := Item{
@@ -46,6 +63,10 @@ item "Sapphire Staff" {
 			MaxStamina:   40,
 			StaminaRegen: 3,
 		},
+		Light: LightComponent{
+			Brightness: 200,
+			Radius:     4,
+		},
 	}
 
 	data, err := ParseItems(raw)
@@ -66,6 +87,8 @@ func TestParseCreature(t *testing.T) {
 	raw := `
 creature "Goblin" {
   icon = 2345
+  behavior = "coward"
+  sight = 7
   skills = ["fireball", "ice-armor"]
   stats {
     move = 5
@@ -82,6 +105,8 @@ creature "Goblin" {
 		Name:         "Goblin",
 		Icon:         2345,
 		InnateSkills: []string{"fireball", "ice-armor"},
+		Behavior:     "coward",
+		SightRange:   7,
 		StatComponent: StatComponent{
 			Movement:     5,
 			MaxLife:      30,
@@ -111,11 +136,45 @@ func TestParseTile(t *testing.T) {
 	raw := `
 tile "Floor" {
   icons = [1, 2, 3]
+  walkable = true
+  movement_cost = 2
+  blocks_sight = true
+  blocks_light = true
+  toggle = "Other Floor"
+  light {
+    brightness = 230
+  }
+  hazard {
+    step_damage = 5
+    turn_end_damage = 3
+    stamina_drain = 10
+    stops_movement = true
+    hidden = true
+    disguise = "Other Floor"
+    detect_range = 2
+  }
 }`
 
 	expected := Tile{
-		Name:  "Floor",
-		Icons: []int{1, 2, 3},
+		Name:         "Floor",
+		Icons:        []int{1, 2, 3},
+		Walkable:     true,
+		MovementCost: 2,
+		BlocksSight:  true,
+		BlocksLight:  true,
+		Toggle:       "Other Floor",
+		Light: LightComponent{
+			Brightness: 230,
+		},
+		Hazard: HazardComponent{
+			StepDamage:    5,
+			TurnEndDamage: 3,
+			StaminaDrain:  10,
+			StopsMovement: true,
+			Hidden:        true,
+			Disguise:      "Other Floor",
+			DetectRange:   2,
+		},
 	}
 
 	data, err := ParseItems(raw)
@@ -143,6 +202,7 @@ skill "Fireball" {
 
   damage = 10
   stamina_cost = 10
+  noise = 3
 
   damage_bonuses {
     int = 0.2
@@ -163,6 +223,7 @@ skill "Fireball" {
 		TargetsGround: true,
 		Damage:        10,
 		StaminaCost:   10,
+		Noise:         3,
 		DamageBonuses: StatModifiers{
 			Int: 0.2,
 		},
@@ -183,3 +244,713 @@ skill "Fireball" {
 		t.Fatalf("bad: \n%v\n%v", data.Skills[0], expected)
 	}
 }
+
+func TestParseBossPhases(t *testing.T) {
+	raw := `
+creature "Lich" {
+  icon = 2345
+  boss = true
+  loot_table = "Hoard"
+
+  phase "Enraged" {
+    life_percent = 50
+    behavior = "caster"
+    skills = ["Fireball"]
+    summons = ["Skeleton", "Skeleton"]
+    bonus {
+      int = 5
+    }
+  }
+}`
+
+	expected := []BossPhase{
+		{
+			Name:        "Enraged",
+			LifePercent: 50,
+			Behavior:    "caster",
+			Skills:      []string{"Fireball"},
+			Bonuses: StatComponent{
+				Intelligence: 5,
+			},
+			Summons: []string{"Skeleton", "Skeleton"},
+		},
+	}
+
+	data, err := ParseItems(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Creatures) != 1 {
+		t.Fatalf("bad: %v", len(data.Creatures))
+	}
+
+	creature := data.Creatures[0]
+	if !creature.Boss {
+		t.Fatalf("bad: creature should be a boss")
+	}
+	if creature.LootTable != "Hoard" {
+		t.Fatalf("bad: %v", creature.LootTable)
+	}
+	if !reflect.DeepEqual(creature.Phases, expected) {
+		t.Fatalf("bad: \n%v\n%v", creature.Phases, expected)
+	}
+}
+
+func TestParseRoomTheme(t *testing.T) {
+	raw := `
+theme "Treasure Vault" {
+  weight = 2
+  creatures = 1
+  extra_creatures = 3
+  items = 2
+  extra_items = 1
+
+  creature "Skeleton" { weight = 3 }
+  creature "Kobold" { weight = 1 }
+
+  item "Torch" { weight = 1 }
+
+  hazards = 1
+  hazard "Pit" { weight = 2 }
+
+  light {
+    brightness = 200
+  }
+}`
+
+	expected := RoomTheme{
+		Name:           "Treasure Vault",
+		Weight:         2,
+		Creatures:      1,
+		ExtraCreatures: 3,
+		Items:          2,
+		ExtraItems:     1,
+		CreatureTable: SpawnTable{
+			{Name: "Skeleton", Weight: 3},
+			{Name: "Kobold", Weight: 1},
+		},
+		ItemTable: SpawnTable{
+			{Name: "Torch", Weight: 1},
+		},
+		Hazards: 1,
+		HazardTable: SpawnTable{
+			{Name: "Pit", Weight: 2},
+		},
+		Light: LightComponent{
+			Brightness: 200,
+		},
+	}
+
+	data, err := ParseItems(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Themes) != 1 {
+		t.Fatalf("bad: %v", len(data.Themes))
+	}
+
+	if !reflect.DeepEqual(data.Themes[0], expected) {
+		t.Fatalf("bad: \n%v\n%v", data.Themes[0], expected)
+	}
+}
+
+func TestSpawnTableRoll(t *testing.T) {
+	table := SpawnTable{
+		{Name: "common", Weight: 9},
+		{Name: "rare", Weight: 1},
+	}
+
+	counts := make(map[string]int)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		counts[table.Roll(random)]++
+	}
+	if counts["common"]+counts["rare"] != 1000 || counts["rare"] == 0 || counts["common"] < 8*counts["rare"] {
+		t.Fatalf("bad: %v", counts)
+	}
+
+	if name := (SpawnTable{}).Roll(random); name != "" {
+		t.Fatalf("bad: %q", name)
+	}
+}
+
+func TestParseLootTable(t *testing.T) {
+	raw := `
+loot_table "Hoard" {
+  rolls = 3
+  chance = 50
+  always = ["Sapphire Staff"]
+  item "Torch" { weight = 2 }
+  item "Ice Spear" { weight = 1 }
+}`
+
+	expected := LootTable{
+		Name:   "Hoard",
+		Rolls:  3,
+		Chance: 50,
+		Always: []string{"Sapphire Staff"},
+		Items: SpawnTable{
+			{Name: "Torch", Weight: 2},
+			{Name: "Ice Spear", Weight: 1},
+		},
+	}
+
+	data, err := ParseItems(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Loot) != 1 {
+		t.Fatalf("bad: %v", len(data.Loot))
+	}
+
+	if !reflect.DeepEqual(data.Loot[0], expected) {
+		t.Fatalf("bad: \n%v\n%v", data.Loot[0], expected)
+	}
+}
+
+func TestLootTableRoll(t *testing.T) {
+	table := LootTable{
+		Rolls:  2,
+		Chance: 50,
+		Items:  SpawnTable{{Name: "Torch", Weight: 1}},
+	}
+
+	// The same seed always gives the same drops
+	first := table.Roll(rand.New(rand.NewSource(7)))
+	if again := table.Roll(rand.New(rand.NewSource(7))); !reflect.DeepEqual(first, again) {
+		t.Fatalf("bad: %v %v", first, again)
+	}
+
+	drops := 0
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		roll := table.Roll(random)
+		if len(roll) > 2 {
+			t.Fatalf("bad: %v", roll)
+		}
+		drops += len(roll)
+	}
+	if drops < 800 || drops > 1200 {
+		t.Fatalf("bad: %d drops from 2000 rolls at 50%%", drops)
+	}
+
+	// Tables always drop something if they don't say otherwise
+	if roll := (LootTable{Items: table.Items}).Roll(random); len(roll) != 1 {
+		t.Fatalf("bad: %v", roll)
+	}
+
+	// The items a table always drops come first, whatever the rolls do
+	hoard := LootTable{Chance: 1, Always: []string{"Sapphire Staff", "Ice Spear"}, Items: table.Items}
+	if roll := hoard.Roll(random); len(roll) < 2 || roll[0] != "Sapphire Staff" || roll[1] != "Ice Spear" {
+		t.Fatalf("bad: %v", roll)
+	}
+}
+
+func TestParseAffix(t *testing.T) {
+	raw := `
+rarity "Rare" {
+  weight = 10
+  prefixes = 1
+  suffixes = 2
+}
+
+affix "of Flames" {
+  kind = "suffix"
+  weight = 2
+  slots = ["weapon"]
+  skills = ["Fireball"]
+  reqs {
+    int = 14
+  }
+  bonus {
+    int = 3
+  }
+}`
+
+	expectedRarity := Rarity{
+		Name:     "Rare",
+		Weight:   10,
+		Prefixes: 1,
+		Suffixes: 2,
+	}
+	expectedAffix := Affix{
+		Name:         "of Flames",
+		Kind:         "suffix",
+		Weight:       2,
+		Slots:        []string{"weapon"},
+		Skills:       []string{"Fireball"},
+		Requirements: StatComponent{Intelligence: 14},
+		Bonuses:      StatComponent{Intelligence: 3},
+	}
+
+	data, err := ParseItems(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Rarities) != 1 || len(data.Affixes) != 1 {
+		t.Fatalf("bad: %v %v", len(data.Rarities), len(data.Affixes))
+	}
+
+	if !reflect.DeepEqual(data.Rarities[0], expectedRarity) {
+		t.Fatalf("bad: \n%v\n%v", data.Rarities[0], expectedRarity)
+	}
+	if !reflect.DeepEqual(data.Affixes[0], expectedAffix) {
+		t.Fatalf("bad: \n%v\n%v", data.Affixes[0], expectedAffix)
+	}
+
+	// Affixes can only be limited to slots equipment goes in
+	for _, slot := range []string{"boots", "consumable"} {
+		raw := fmt.Sprintf(`affix "Swift" {
+  kind = "prefix"
+  weight = 1
+  slots = ["%s"]
+}`, slot)
+		if err := loadDataString(t, raw); err == nil {
+			t.Fatalf("bad: loaded an affix for slot '%s'", slot)
+		}
+	}
+	if err := loadDataString(t, `affix "Swift" {
+  kind = "prefix"
+  weight = 1
+  slots = ["weapon", "helm"]
+}`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Loads the given data through a temporary file, returning any error from checking it
+func loadDataString(t *testing.T, raw string) error {
+	file, err := ioutil.TempFile("", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(raw); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	return LoadDataFile(file.Name())
+}
+
+func TestRollItem(t *testing.T) {
+	if err := LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+	template := GetItemData("Ice Spear")
+
+	random := rand.New(rand.NewSource(5))
+	for i := 0; i < 200; i++ {
+		seed := random.Int63()
+		item := RollItem("Ice Spear", GridPoint{}, rand.New(rand.NewSource(seed)))
+		again := RollItem("Ice Spear", GridPoint{}, rand.New(rand.NewSource(seed)))
+		if item.DisplayName() != again.DisplayName() || item.Bonuses != again.Bonuses || !reflect.DeepEqual(item.Skills, again.Skills) {
+			t.Fatalf("bad: rolled %s and %s from the same seed", item.DisplayName(), again.DisplayName())
+		}
+
+		rarity := GetRarity(item.Rarity)
+		if rarity.Name == "" || len(item.Affixes) > rarity.Prefixes+rarity.Suffixes {
+			t.Fatalf("bad: %s item with affixes %v", item.Rarity, item.Affixes)
+		}
+		for _, name := range item.Affixes {
+			if !GetAffix(name).FitsSlot(item.Slot) {
+				t.Fatalf("bad: affix %s on a %s", name, item.Slot)
+			}
+		}
+	}
+
+	// Rolling items shouldn't change the template they're made from
+	if !reflect.DeepEqual(GetItemData("Ice Spear"), template) {
+		t.Fatalf("bad: \n%v\n%v", GetItemData("Ice Spear"), template)
+	}
+}
+
+func TestParseConsumable(t *testing.T) {
+	raw := `
+item "Potion of Haste" {
+  slot = "consumable"
+  icon = 1882
+  charges = 2
+  use {
+    heal = 5
+    status {
+      name = "Haste"
+      turns = 3
+      bonus {
+        move = 3
+      }
+    }
+  }
+}`
+
+	expected := Item{
+		Name:    "Potion of Haste",
+		Slot:    "consumable",
+		Icon:    1882,
+		Charges: 2,
+		Use: UseComponent{
+			Heal: 5,
+			Status: StatusEffect{
+				Name:    "Haste",
+				Turns:   3,
+				Bonuses: StatComponent{Movement: 3},
+			},
+		},
+	}
+
+	data, err := ParseItems(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Items) != 1 {
+		t.Fatalf("bad: %v", len(data.Items))
+	}
+
+	if !reflect.DeepEqual(data.Items[0], expected) {
+		t.Fatalf("bad: \n%v\n%v", data.Items[0], expected)
+	}
+}
+
+func TestStatusEffects(t *testing.T) {
+	creature := Creature{StatComponent: StatComponent{Movement: 4}}
+	creature.AddStatus(StatusEffect{Name: "Haste", Turns: 2, Bonuses: StatComponent{Movement: 3}})
+	creature.AddStatus(StatusEffect{Name: "Haste", Turns: 1, Bonuses: StatComponent{Movement: 2}})
+
+	if len(creature.Statuses) != 1 || creature.Stats().Movement != 6 {
+		t.Fatalf("bad: %v with movement %d", creature.Statuses, creature.Stats().Movement)
+	}
+
+	creature.TickStatuses()
+	if len(creature.Statuses) != 0 || creature.Stats().Movement != 4 {
+		t.Fatalf("bad: %v with movement %d", creature.Statuses, creature.Stats().Movement)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	if err := LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	creature := Creature{InventorySlots: 2}
+	if creature.InventoryCapacity() != 2 {
+		t.Fatalf("bad: %d", creature.InventoryCapacity())
+	}
+	creature.Equipment[Accessory] = NewItem("Traveler's Pack", GridPoint{})
+	if creature.InventoryCapacity() != 4 {
+		t.Fatalf("bad: %d", creature.InventoryCapacity())
+	}
+
+	// Unused potions stack until the stack is full
+	potion := NewItem("Healing Potion", GridPoint{})
+	creature.SetInventoryItem(creature.FreeInventorySlot(), potion)
+	for i := 1; i < potion.StackSize; i++ {
+		if slot := creature.StackSlot(NewItem("Healing Potion", GridPoint{})); slot != 0 {
+			t.Fatalf("bad: %d", slot)
+		}
+		potion.Count++
+	}
+	if slot := creature.StackSlot(NewItem("Healing Potion", GridPoint{})); slot != -1 {
+		t.Fatalf("bad: %d", slot)
+	}
+
+	// Used items and other items go in their own slot
+	bomb := NewItem("Fire Bomb", GridPoint{})
+	bomb.Charges--
+	if creature.StackSlot(bomb) != -1 || creature.FreeInventorySlot() != 1 {
+		t.Fatalf("bad: %v", creature.Inventory)
+	}
+
+	// Taking off the pack leaves anything past the capacity where it is, but nothing new goes there
+	creature.SetInventoryItem(3, bomb)
+	creature.SetInventoryItem(1, NewItem("Torch", GridPoint{}))
+	creature.Equipment[Accessory] = nil
+	if creature.InventoryItem(3) != bomb || creature.FreeInventorySlot() != -1 || creature.HasRoomFor(NewItem("Torch", GridPoint{})) {
+		t.Fatalf("bad: %v", creature.Inventory)
+	}
+}
+
+func TestEquipment(t *testing.T) {
+	if err := LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	creature := Creature{StatComponent: StatComponent{MaxLife: 10, MaxStamina: 20}, InventorySlots: 1}
+	creature.Life = 10
+	creature.Stamina = 20
+	armor := NewItem("Leather Armor", GridPoint{})
+	armor.Bonuses.MaxStamina = 5
+	creature.SetInventoryItem(0, armor)
+
+	if err := creature.EquipFromInventory(0); err != nil {
+		t.Fatal(err)
+	}
+	if creature.Equipment[Armor] != armor || creature.Life != 20 || creature.Stamina != 25 {
+		t.Fatalf("bad: life %d, stamina %d", creature.Life, creature.Stamina)
+	}
+
+	// Taking the armor off when badly hurt or tired shouldn't kill or drain the creature
+	creature.Life = 5
+	creature.Stamina = 2
+	if err := creature.UnequipToInventory(Armor); err != nil {
+		t.Fatal(err)
+	}
+	if creature.InventoryItem(0) != armor || creature.Life != 1 || creature.Stamina != 0 {
+		t.Fatalf("bad: life %d, stamina %d", creature.Life, creature.Stamina)
+	}
+
+	// Nothing gets lost unequipping into a full inventory
+	creature.EquipFromInventory(0)
+	creature.SetInventoryItem(0, NewItem("Torch", GridPoint{}))
+	if err := creature.UnequipToInventory(Armor); err == nil || creature.Equipment[Armor] != armor {
+		t.Fatalf("bad: %v", err)
+	}
+
+	staff := NewItem("Sapphire Staff", GridPoint{})
+	staff.Requirements.Intelligence = 50
+	creature.SetInventoryItem(0, staff)
+	if err := creature.EquipFromInventory(0); err == nil || creature.InventoryItem(0) != staff {
+		t.Fatalf("bad: %v", err)
+	}
+	if err := creature.EquipFromInventory(3); err == nil {
+		t.Fatal("bad: equipped from an empty slot")
+	}
+}
+
+func TestStats(t *testing.T) {
+	creature := Creature{StatComponent: StatComponent{MaxLife: 20, Strength: 10, StaminaRegen: 4}}
+	creature.Stamina = 7
+	creature.LevelBonuses = StatComponent{Strength: 2}
+	creature.Equipment[Armor] = &Item{
+		Bonuses:        StatComponent{MaxLife: 10, StaminaRegen: 2},
+		PercentBonuses: StatComponent{MaxLife: 50},
+	}
+	creature.AddStatus(StatusEffect{Name: "Rage", Turns: 1, PercentBonuses: StatComponent{Strength: 50}})
+
+	expected := StatComponent{MaxLife: 45, Strength: 18, Stamina: 7, StaminaRegen: 6}
+	if stats := creature.Stats(); stats != expected {
+		t.Fatalf("bad: \n%v\n%v", stats, expected)
+	}
+
+	// Base stats are read fresh, but bonuses are cached until they're invalidated
+	creature.MaxLife = 30
+	creature.Equipment[Armor] = nil
+	if stats := creature.Stats(); stats.MaxLife != 60 {
+		t.Fatalf("bad: %d", stats.MaxLife)
+	}
+	creature.InvalidateStats()
+	if stats := creature.Stats(); stats.MaxLife != 30 || stats.StaminaRegen != 4 {
+		t.Fatalf("bad: %v", stats)
+	}
+
+	creature.TickStatuses()
+	if stats := creature.Stats(); stats.Strength != 12 {
+		t.Fatalf("bad: %d", stats.Strength)
+	}
+
+	// Bosses get the bonuses of every phase they've reached, without changing their base stats
+	creature.Phases = []BossPhase{{Bonuses: StatComponent{Strength: 5}}, {Bonuses: StatComponent{Strength: 3}}}
+	creature.Phase = 1
+	creature.InvalidateStats()
+	if stats := creature.Stats(); stats.Strength != 17 || creature.Strength != 10 {
+		t.Fatalf("bad: %d with base %d", stats.Strength, creature.Strength)
+	}
+}
+
+func TestGainXP(t *testing.T) {
+	creature := Creature{StatComponent: StatComponent{MaxLife: 40, Strength: 10}, Level: 1}
+	creature.Life = 30
+	creature.LevelUp = StatComponent{MaxLife: 5}
+
+	if levels := creature.GainXP(XPForLevel(2) - 1); levels != 0 || creature.Level != 1 {
+		t.Fatalf("bad: %d levels, level %d", levels, creature.Level)
+	}
+	if levels := creature.GainXP(XPForLevel(4) - XPForLevel(2) + 1); levels != 3 {
+		t.Fatalf("bad: %d levels", levels)
+	}
+	if creature.Level != 4 || creature.StatPoints != 3*StatPointsPerLevel || creature.Stats().MaxLife != 55 || creature.Life != 45 {
+		t.Fatalf("bad: level %d, %d points, life %d/%d", creature.Level, creature.StatPoints, creature.Life, creature.Stats().MaxLife)
+	}
+
+	if err := creature.SpendStatPoint(StrengthStat); err != nil {
+		t.Fatal(err)
+	}
+	if err := creature.SpendStatPoint("luck"); err == nil {
+		t.Fatal("bad: spent a point on an unknown stat")
+	}
+	if creature.Stats().Strength != 11 || creature.StatPoints != 3*StatPointsPerLevel-1 {
+		t.Fatalf("bad: str %d, %d points", creature.Stats().Strength, creature.StatPoints)
+	}
+}
+
+func TestClasses(t *testing.T) {
+	if err := LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	player := NewPlayer("Mage", GridPoint{})
+	mage := GetClass("Mage")
+	if player.Class != "Mage" || player.Icon != mage.Icon || player.MaxLife != mage.Stats.MaxLife || player.Life != player.MaxLife {
+		t.Fatalf("bad: %+v", player)
+	}
+	if !reflect.DeepEqual(player.InnateSkills, []string{"Basic Attack", "Fireball"}) {
+		t.Fatalf("bad: %v", player.InnateSkills)
+	}
+
+	// Skills unlock as the player reaches their level
+	player.GainXP(XPForLevel(3))
+	if !reflect.DeepEqual(player.InnateSkills, []string{"Basic Attack", "Fireball", "Ice Storm"}) {
+		t.Fatalf("bad: %v", player.InnateSkills)
+	}
+
+	// Unknown classes fall back to the first one
+	if player := NewPlayer("Bard", GridPoint{}); player.Class != GetClasses()[0].Name {
+		t.Fatalf("bad: %s", player.Class)
+	}
+
+	// Classes without stats would start with no life
+	if err := loadDataString(t, `class "Bard" { icon = 598 }`); err == nil {
+		t.Fatal("bad: loaded a class with no stats")
+	}
+}
+
+func TestCharacterSave(t *testing.T) {
+	if err := LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "saves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	player := NewPlayer("Warrior", GridPoint{})
+	player.Character = "Bob"
+	player.Equipment[Armor] = NewItem("Leather Armor", GridPoint{})
+	player.Equipment[Armor].Rarity = "Magic"
+	player.Equipment[Armor].ApplyAffix(GetAffix("Sturdy"))
+	player.SetInventoryItem(1, NewItem("Healing Potion", GridPoint{}))
+	player.Inventory[1].Count = 3
+	player.GainXP(XPForLevel(3))
+	player.SpendStatPoint(DexterityStat)
+	player.Life = 20
+	if err := SaveCharacter(dir, player); err != nil {
+		t.Fatal(err)
+	}
+
+	save, err := LoadCharacter(dir, "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := save.Character(GridPoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Level != 3 || loaded.StatPoints != player.StatPoints || loaded.Stats() != player.Stats() || loaded.Life != 20 {
+		t.Fatalf("bad: %+v", loaded.Stats())
+	}
+	if !reflect.DeepEqual(loaded.InnateSkills, player.InnateSkills) {
+		t.Fatalf("bad: %v", loaded.InnateSkills)
+	}
+	if armor := loaded.Equipment[Armor]; armor.DisplayName() != "Sturdy Leather Armor" || loaded.InventoryItem(1).Count != 3 {
+		t.Fatalf("bad: %s", armor.DisplayName())
+	}
+
+	// Items that don't exist get the save rejected, unless it's from an older data version
+	save.Inventory[0] = SavedItem{Name: "Vorpal Sword", Count: 1}
+	if err := save.Validate(); err == nil {
+		t.Fatal("bad: loaded a save with an unknown item")
+	}
+	save.DataVersion--
+	if changes := save.Upgrade(); len(changes) != 1 || save.Inventory[0].Name != "" {
+		t.Fatalf("bad: %v", changes)
+	}
+	if err := save.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// So do affixes the item couldn't have rolled
+	armor := save.Equipment[Armor]
+	for i, affixes := range [][]string{{"Sturdy", "Sturdy"}, {"Sturdy", "Towering"}, {"Sturdy", "of the Bear"}} {
+		save.Equipment[Armor].Affixes = affixes
+		if err := save.Validate(); err == nil {
+			t.Fatalf("bad: case %d: loaded armor with affixes %v", i, affixes)
+		}
+	}
+	save.Equipment[Armor] = armor
+	save.Inventory[1].Affixes = []string{"of Vigor"}
+	if err := save.Validate(); err == nil {
+		t.Fatal("bad: loaded a potion with an affix")
+	}
+	save.Inventory[1].Affixes = nil
+
+	// So does spending points that weren't earned
+	save.SpentPoints[StrengthStat] = 10
+	if err := save.Validate(); err == nil {
+		t.Fatal("bad: loaded a save with too many stat points")
+	}
+
+	if save, err := LoadCharacter(dir, "Alice"); save != nil || err != nil {
+		t.Fatalf("bad: %v %v", save, err)
+	}
+}
+
+func TestSavedCreature(t *testing.T) {
+	if err := LoadDataFile("../data.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	king := NewCreature("Skeleton King", GridPoint{X: 3, Y: 4})
+	king.Phase = 1
+	king.MaxLife += 10
+	king.Life = 25
+	king.Awareness = Hunting
+	king.Equipment[Weapon] = NewItem("Ice Spear", GridPoint{})
+	king.AddStatus(StatusEffect{Name: "Haste", Turns: 2, Bonuses: StatComponent{Movement: 3}})
+
+	saved := NewSavedCreature(king)
+	loaded, err := saved.Creature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Stats() != king.Stats() || loaded.Life != 25 || loaded.Phase != 1 || loaded.Awareness != Hunting {
+		t.Fatalf("bad: %+v", loaded.Stats())
+	}
+	if loaded.Equipment[Weapon].Name != "Ice Spear" || len(loaded.StartingItems) != 0 || PointToGridPoint(loaded.Position) != (GridPoint{X: 3, Y: 4}) {
+		t.Fatalf("bad: %+v", loaded.Equipment)
+	}
+
+	saved.Equipment[Armor] = SavedItem{Name: "Ice Spear", Count: 1}
+	if _, err := saved.Creature(); err == nil {
+		t.Fatal("bad: loaded a creature with a weapon in its armor slot")
+	}
+
+	// Hidden hazards keep their disguise until they're found
+	tile := NewTile("Spike Trap", GridPoint{X: 1, Y: 2}, rand.New(rand.NewSource(1)))
+	restored := NewSavedTile(tile).Tile()
+	if restored.DisguiseSprite != tile.DisguiseSprite || restored.Sprite != tile.Sprite || !restored.IsHidden() {
+		t.Fatalf("bad: %+v", restored)
+	}
+	tile.Reveal()
+	if restored := NewSavedTile(tile).Tile(); restored.IsHidden() || restored.GridPoint != tile.GridPoint {
+		t.Fatalf("bad: %+v", restored)
+	}
+
+	// Disguises have to be one of the disguise tile's icons, and only on hidden hazards
+	savedTile := NewSavedTile(tile)
+	if err := savedTile.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	savedTile.DisguiseSprite = savedTile.Sprite
+	if err := savedTile.Validate(); err == nil {
+		t.Fatal("bad: loaded a trap disguised as itself")
+	}
+	savedTile = NewSavedTile(NewTile("Dungeon Floor", GridPoint{}, rand.New(rand.NewSource(1))))
+	savedTile.DisguiseSprite = tile.DisguiseSprite
+	if err := savedTile.Validate(); err == nil {
+		t.Fatal("bad: loaded a disguised floor")
+	}
+}
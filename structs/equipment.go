@@ -0,0 +1,75 @@
+package structs
+
+import (
+	"fmt"
+
+	"github.com/engoengine/math/imath"
+)
+
+// EquipFromInventory equips the item in the given inventory slot, putting whatever was already
+// equipped in its place back into that inventory slot
+func (c *Creature) EquipFromInventory(slot int) error {
+	item := c.InventoryItem(slot)
+	if item == nil {
+		return fmt.Errorf("There's no item in inventory slot %d", slot+1)
+	}
+	if err := c.EquipError(item); err != nil {
+		return err
+	}
+
+	old := c.Stats()
+	c.Inventory[slot] = c.Equipment[item.Type]
+	c.Equipment[item.Type] = item
+	c.adjustForMaxStats(old)
+	return nil
+}
+
+// UnequipError returns why the creature can't take off the item in the given equipment slot,
+// or nil if it can
+func (c *Creature) UnequipError(slot int) error {
+	if slot < 0 || slot >= EquipmentSlots || c.Equipment[slot] == nil {
+		return fmt.Errorf("There's no item in equipment slot %d", slot+1)
+	}
+
+	// Taking off a bag leaves less room for the item to go in
+	item := c.Equipment[slot]
+	c.Equipment[slot] = nil
+	free := c.FreeInventorySlot()
+	c.Equipment[slot] = item
+	if free == -1 {
+		return fmt.Errorf("No room in the inventory for %s", item.DisplayName())
+	}
+	return nil
+}
+
+// UnequipToInventory takes off the item in the given equipment slot and puts it in the first
+// free inventory slot
+func (c *Creature) UnequipToInventory(slot int) error {
+	if err := c.UnequipError(slot); err != nil {
+		return err
+	}
+
+	old := c.Stats()
+	item := c.Equipment[slot]
+	c.Equipment[slot] = nil
+	c.SetInventoryItem(c.FreeInventorySlot(), item)
+	c.adjustForMaxStats(old)
+	return nil
+}
+
+// EquipNew puts a new item straight into its equipment slot, which should be empty
+func (c *Creature) EquipNew(item *Item) {
+	old := c.Stats()
+	c.Equipment[item.Type] = item
+	c.adjustForMaxStats(old)
+}
+
+// Recalculates the creature's stats after a change from the old ones, moving life and stamina
+// along with their max values. Changing equipment never kills the creature or leaves it with
+// more than its max life or stamina.
+func (c *Creature) adjustForMaxStats(old StatComponent) {
+	c.InvalidateStats()
+	stats := c.Stats()
+	c.Life = imath.Max(1, imath.Min(c.Life+stats.MaxLife-old.MaxLife, stats.MaxLife))
+	c.Stamina = imath.Max(0, imath.Min(c.Stamina+stats.MaxStamina-old.MaxStamina, stats.MaxStamina))
+}
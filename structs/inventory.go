@@ -0,0 +1,82 @@
+package structs
+
+import "github.com/engoengine/math/imath"
+
+// InventoryCapacity returns how many inventory slots the creature has, counting the extra
+// room given by its equipment
+func (c *Creature) InventoryCapacity() int {
+	capacity := InventorySize
+	if c.InventorySlots > 0 {
+		capacity = c.InventorySlots
+	}
+	for _, item := range c.Equipment {
+		if item != nil {
+			capacity += item.InventorySlots
+		}
+	}
+	return imath.Min(capacity, MaxInventorySize)
+}
+
+// InventoryItem returns the item in the given inventory slot, or nil if there isn't one
+func (c *Creature) InventoryItem(slot int) *Item {
+	if slot < 0 || slot >= len(c.Inventory) {
+		return nil
+	}
+	return c.Inventory[slot]
+}
+
+// SetInventoryItem puts the item in the given inventory slot, making room for the slot if
+// the inventory hasn't grown that big yet
+func (c *Creature) SetInventoryItem(slot int, item *Item) {
+	for len(c.Inventory) <= slot {
+		c.Inventory = append(c.Inventory, nil)
+	}
+	c.Inventory[slot] = item
+}
+
+// FreeInventorySlot returns the first empty inventory slot, or -1 if the inventory is full.
+// Items can be left in slots past the creature's capacity when it takes off a bag, but
+// nothing new goes there.
+func (c *Creature) FreeInventorySlot() int {
+	for i := 0; i < c.InventoryCapacity(); i++ {
+		if c.InventoryItem(i) == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// StackSlot returns the inventory slot holding a stack the item can be added to, or -1 if
+// there isn't one
+func (c *Creature) StackSlot(item *Item) int {
+	for i, existing := range c.Inventory {
+		if existing != nil && existing.CanStack(item) {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasRoomFor returns whether the item can go in the creature's inventory, either in an empty
+// slot or on top of a stack
+func (c *Creature) HasRoomFor(item *Item) bool {
+	return c.StackSlot(item) != -1 || c.FreeInventorySlot() != -1
+}
+
+// MaxCharges returns how many charges a fresh copy of the item has
+func (item *Item) MaxCharges() int {
+	charges := GetItemData(item.Name).Charges
+	if item.IsConsumable() && charges < 1 {
+		return 1
+	}
+	return charges
+}
+
+// CanStack returns whether the other item can be added to this item's stack. Only unused
+// copies can go on a stack, so that everything under the top of it has full charges.
+func (item *Item) CanStack(other *Item) bool {
+	if item == other || item.Name != other.Name || item.StackSize < 2 {
+		return false
+	}
+	return item.Count+other.Count <= item.StackSize && other.Charges == other.MaxCharges()
+}
@@ -0,0 +1,63 @@
+package structs
+
+import "fmt"
+
+// How many stat points a creature gets to spend each time it levels up
+const StatPointsPerLevel = 3
+
+// The stats that level-up points can be spent on
+const (
+	StrengthStat     = "str"
+	DexterityStat    = "dex"
+	IntelligenceStat = "int"
+)
+
+// XPForLevel returns the total experience needed to reach the given level. Each level takes
+// 100 more experience than the one before it.
+func XPForLevel(level int) int {
+	return 50 * level * (level - 1)
+}
+
+// GainXP adds experience to the creature, levelling it up as many times as it's earned. Each
+// level gives the creature's level_up stats, heals it by the life and stamina gained, gives it
+// stat points to spend and teaches it any skills its class unlocks at that level. Returns how
+// many levels were gained.
+func (c *Creature) GainXP(amount int) int {
+	c.Experience += amount
+	levels := 0
+	for c.Experience >= XPForLevel(c.Level+1) {
+		c.Level++
+		levels++
+		c.StatPoints += StatPointsPerLevel
+		c.LevelBonuses = c.LevelBonuses.Plus(c.LevelUp)
+		c.Life += c.LevelUp.MaxLife
+		c.Stamina += c.LevelUp.MaxStamina
+		if c.Class != "" {
+			c.InnateSkills = append(c.InnateSkills, GetClass(c.Class).SkillsAt(c.Level)...)
+		}
+	}
+	if levels > 0 {
+		c.InvalidateStats()
+	}
+	return levels
+}
+
+// SpendStatPoint uses one of the creature's unspent stat points to raise the given stat
+func (c *Creature) SpendStatPoint(stat string) error {
+	if c.StatPoints < 1 {
+		return fmt.Errorf("No stat points left to spend")
+	}
+	switch stat {
+	case StrengthStat:
+		c.LevelBonuses.Strength++
+	case DexterityStat:
+		c.LevelBonuses.Dexterity++
+	case IntelligenceStat:
+		c.LevelBonuses.Intelligence++
+	default:
+		return fmt.Errorf("Can't spend stat points on '%s'", stat)
+	}
+	c.StatPoints--
+	c.InvalidateStats()
+	return nil
+}
@@ -0,0 +1,43 @@
+package structs
+
+import "math/rand"
+
+// LootTable is a list of items a creature might drop when it dies
+type LootTable struct {
+	Name string `hcl:",key"`
+
+	// How many times to roll on the table, 1 if left out
+	Rolls int
+
+	// The percent chance of each roll dropping anything, 100 if left out
+	Chance int
+
+	// Items dropped every time, on top of the rolls, such as a boss's treasure
+	Always []string
+
+	Items SpawnTable `hcl:"item"`
+}
+
+// Roll returns the names of the items dropped from the table. random should be seeded the
+// same way on every client so everyone sees the same drops.
+func (t LootTable) Roll(random *rand.Rand) []string {
+	rolls := t.Rolls
+	if rolls < 1 {
+		rolls = 1
+	}
+	chance := t.Chance
+	if chance <= 0 {
+		chance = 100
+	}
+
+	drops := append([]string(nil), t.Always...)
+	for i := 0; i < rolls; i++ {
+		if random.Intn(100) >= chance {
+			continue
+		}
+		if name := t.Items.Roll(random); name != "" {
+			drops = append(drops, name)
+		}
+	}
+	return drops
+}
@@ -0,0 +1,225 @@
+package structs
+
+import "fmt"
+
+// SavedTile is a tile on the floor of a saved game, with the icons it was given
+type SavedTile struct {
+	Name     string    `json:"name"`
+	Position GridPoint `json:"position"`
+
+	Sprite         int  `json:"sprite"`
+	DisguiseSprite int  `json:"disguise_sprite,omitempty"`
+	Detected       bool `json:"detected,omitempty"`
+}
+
+// SavedCreature is a creature in a saved game. Players are saved along with their character,
+// which their stats and items come from.
+type SavedCreature struct {
+	Name     string    `json:"name"`
+	Position GridPoint `json:"position"`
+
+	// The creature's base stats, which can be raised by the floor it's on and by boss phases,
+	// along with its current life and stamina
+	Stats StatComponent `json:"stats"`
+	Life  int           `json:"life"`
+
+	Behavior     string         `json:"behavior,omitempty"`
+	InnateSkills []string       `json:"skills,omitempty"`
+	Phase        int            `json:"phase,omitempty"`
+	Statuses     []StatusEffect `json:"statuses,omitempty"`
+
+	Awareness    AwarenessState `json:"awareness,omitempty"`
+	LastKnownLoc GridPoint      `json:"last_known_loc"`
+	Home         GridPoint      `json:"home"`
+
+	Equipment []SavedItem `json:"equipment,omitempty"`
+	Inventory []SavedItem `json:"inventory,omitempty"`
+
+	Character *CharacterSave `json:"character,omitempty"`
+}
+
+// GroundItem is an item lying on the floor of a saved game
+type GroundItem struct {
+	SavedItem
+	Position GridPoint `json:"position"`
+}
+
+// NewSavedTile records the tile so it can be saved
+func NewSavedTile(t *Tile) SavedTile {
+	return SavedTile{
+		Name:           t.Name,
+		Position:       t.GridPoint,
+		Sprite:         t.Sprite,
+		DisguiseSprite: t.DisguiseSprite,
+		Detected:       t.Detected,
+	}
+}
+
+// Validate returns an error if the tile doesn't exist in the data file
+func (saved SavedTile) Validate() error {
+	data, ok := tileData[saved.Name]
+	if !ok {
+		return fmt.Errorf("Unrecognized tile: '%s'", saved.Name)
+	}
+	if !hasIcon(data.Icons, saved.Sprite) {
+		return fmt.Errorf("Tile '%s' doesn't have icon %d", saved.Name, saved.Sprite)
+	}
+
+	// Only hidden hazards are disguised, as one of their disguise's icons
+	if disguise := GetTileData(data.Hazard.Disguise); data.Hazard.Hidden && len(disguise.Icons) > 0 {
+		if !hasIcon(disguise.Icons, saved.DisguiseSprite) {
+			return fmt.Errorf("Tile '%s' can't be disguised as icon %d", saved.Name, saved.DisguiseSprite)
+		}
+	} else if saved.DisguiseSprite != 0 {
+		return fmt.Errorf("Tile '%s' isn't disguised, but has disguise icon %d", saved.Name, saved.DisguiseSprite)
+	}
+	return nil
+}
+
+func hasIcon(icons []int, icon int) bool {
+	for _, i := range icons {
+		if i == icon {
+			return true
+		}
+	}
+	return false
+}
+
+// Tile makes the saved tile
+func (saved SavedTile) Tile() *Tile {
+	tile := newTile(saved.Name, saved.Position, saved.Sprite, saved.DisguiseSprite)
+	if saved.Detected {
+		tile.Reveal()
+	}
+	return tile
+}
+
+// NewSavedCreature records the creature so it can be saved
+func NewSavedCreature(c *Creature) SavedCreature {
+	saved := SavedCreature{
+		Name:         c.Name,
+		Position:     PointToGridPoint(c.Position),
+		Stats:        c.StatComponent,
+		Life:         c.Life,
+		Behavior:     c.Behavior,
+		InnateSkills: c.InnateSkills,
+		Phase:        c.Phase,
+		Statuses:     c.Statuses,
+		Awareness:    c.Awareness,
+		LastKnownLoc: c.LastKnownLoc,
+		Home:         c.Home,
+	}
+	if c.IsPlayerTeam {
+		saved.Character = NewCharacterSave(c)
+		return saved
+	}
+
+	for _, item := range c.Equipment {
+		saved.Equipment = append(saved.Equipment, newSavedItem(item))
+	}
+	for _, item := range c.Inventory {
+		saved.Inventory = append(saved.Inventory, newSavedItem(item))
+	}
+	return saved
+}
+
+// Validate returns an error if the creature couldn't exist with the current data file
+func (saved *SavedCreature) Validate() error {
+	if saved.Character != nil {
+		return saved.Character.Validate()
+	}
+
+	data, ok := creatureData[saved.Name]
+	if !ok {
+		return fmt.Errorf("Unrecognized creature: '%s'", saved.Name)
+	}
+	if saved.Phase < 0 || saved.Phase > len(data.Phases) {
+		return fmt.Errorf("%s is in unrecognized phase %d", saved.Name, saved.Phase)
+	}
+	for _, skill := range saved.InnateSkills {
+		if _, ok := skillData[skill]; !ok {
+			return fmt.Errorf("%s has unrecognized skill: '%s'", saved.Name, skill)
+		}
+	}
+	if len(saved.Equipment) > EquipmentSlots || len(saved.Inventory) > MaxInventorySize {
+		return fmt.Errorf("%s has too many item slots", saved.Name)
+	}
+	for slot, item := range saved.Equipment {
+		if item.Name == "" {
+			continue
+		}
+		if err := item.validate(slot); err != nil {
+			return err
+		}
+	}
+	for _, item := range saved.Inventory {
+		if item.Name == "" {
+			continue
+		}
+		if err := item.validate(-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Creature makes the saved creature, or returns an error if it isn't valid
+func (saved *SavedCreature) Creature() (*Creature, error) {
+	if err := saved.Validate(); err != nil {
+		return nil, err
+	}
+
+	var creature *Creature
+	if saved.Character != nil {
+		var err error
+		if creature, err = saved.Character.Character(saved.Position); err != nil {
+			return nil, err
+		}
+	} else {
+		creature = NewCreature(saved.Name, saved.Position)
+		creature.StartingItems = nil
+		creature.StatComponent = saved.Stats
+		creature.Life = saved.Life
+		creature.Behavior = saved.Behavior
+		creature.InnateSkills = saved.InnateSkills
+		creature.Phase = saved.Phase
+		for slot, item := range saved.Equipment {
+			if item.Name != "" {
+				creature.Equipment[slot] = item.item(saved.Position)
+			}
+		}
+		for slot, item := range saved.Inventory {
+			if item.Name != "" {
+				creature.SetInventoryItem(slot, item.item(saved.Position))
+			}
+		}
+	}
+
+	creature.Statuses = saved.Statuses
+	creature.Awareness = saved.Awareness
+	creature.LastKnownLoc = saved.LastKnownLoc
+	creature.Home = saved.Home
+	creature.InvalidateStats()
+	return creature, nil
+}
+
+// NewGroundItem records the item lying on the ground so it can be saved
+func NewGroundItem(item *Item) GroundItem {
+	return GroundItem{
+		SavedItem: newSavedItem(item),
+		Position:  PointToGridPoint(item.Position),
+	}
+}
+
+// Validate returns an error if the item doesn't exist in the data file
+func (saved GroundItem) Validate() error {
+	return saved.validate(-1)
+}
+
+// Item makes the saved item, lying on the ground
+func (saved GroundItem) Item() *Item {
+	item := saved.item(saved.Position)
+	item.OnGround = true
+	item.RenderComponent.Hidden = false
+	return item
+}
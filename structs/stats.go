@@ -0,0 +1,61 @@
+package structs
+
+// The total of the bonuses on a creature's stats. Flat bonuses are added to the base stats
+// first, then the result is raised by the percent bonuses.
+type statModifiers struct {
+	flat    StatComponent
+	percent StatComponent
+}
+
+// Stats returns the creature's effective stats, after the bonuses from its equipment, statuses,
+// levels and boss phases. The bonuses are cached until InvalidateStats is called, while the base stats are
+// read fresh each time so they can be changed directly.
+func (c *Creature) Stats() StatComponent {
+	if !c.statsCached {
+		c.modifiers = c.totalModifiers()
+		c.statsCached = true
+	}
+
+	flat := c.StatComponent.Plus(c.modifiers.flat)
+	percent := c.modifiers.percent
+	return StatComponent{
+		Movement:     applyPercent(flat.Movement, percent.Movement),
+		MaxLife:      applyPercent(flat.MaxLife, percent.MaxLife),
+		Strength:     applyPercent(flat.Strength, percent.Strength),
+		Dexterity:    applyPercent(flat.Dexterity, percent.Dexterity),
+		Intelligence: applyPercent(flat.Intelligence, percent.Intelligence),
+		MaxStamina:   applyPercent(flat.MaxStamina, percent.MaxStamina),
+		Stamina:      c.Stamina,
+		StaminaRegen: applyPercent(flat.StaminaRegen, percent.StaminaRegen),
+	}
+}
+
+// InvalidateStats throws away the cached bonuses, so they get added up again the next time
+// they're needed. It has to be called whenever the creature's equipment, statuses or level
+// bonuses change, or a boss moves on to its next phase.
+func (c *Creature) InvalidateStats() {
+	c.statsCached = false
+}
+
+// Adds up the bonuses from everything affecting the creature
+func (c *Creature) totalModifiers() statModifiers {
+	modifiers := statModifiers{flat: c.LevelBonuses}
+	for _, item := range c.Equipment {
+		if item != nil {
+			modifiers.flat = modifiers.flat.Plus(item.Bonuses)
+			modifiers.percent = modifiers.percent.Plus(item.PercentBonuses)
+		}
+	}
+	for _, status := range c.Statuses {
+		modifiers.flat = modifiers.flat.Plus(status.Bonuses)
+		modifiers.percent = modifiers.percent.Plus(status.PercentBonuses)
+	}
+	for i := 0; i < c.Phase && i < len(c.Phases); i++ {
+		modifiers.flat = modifiers.flat.Plus(c.Phases[i].Bonuses)
+	}
+	return modifiers
+}
+
+func applyPercent(value, percent int) int {
+	return value + value*percent/100
+}
@@ -19,8 +19,21 @@ func LoadSprites() {
 	Sprites = common.NewSpritesheetFromFile(SpritesheetPath, TileWidth, TileWidth)
 }
 
+// Returns the sprite with the given index, or nothing if the sprites haven't been loaded,
+// so game objects can still be made without any graphics (like in tests)
+func spriteCell(index int) common.Drawable {
+	if Sprites == nil {
+		return nil
+	}
+	return Sprites.Cell(index)
+}
+
 const MinBrightness = 80
+
+// Creatures get InventorySize inventory slots unless their data says otherwise, and can't
+// have more than MaxInventorySize however many bags they carry
 const InventorySize = 5
+const MaxInventorySize = 7
 const EquipmentSlots = 5
 const SkillSlots = 10
 
@@ -42,6 +55,45 @@ type StatComponent struct {
 	StaminaRegen int `hcl:"stamina_regen"`
 }
 
+// LightComponent describes the light given off by a tile, item, creature or skill.
+// A zero brightness means the thing doesn't give off any light.
+type LightComponent struct {
+	// The starting brightness alpha value. 255 is full brightness
+	Brightness int `hcl:"brightness" json:"brightness"`
+
+	// How many tiles away the light reaches. If left at 0, the radius is
+	// based on the brightness
+	Radius int `hcl:"radius" json:"radius,omitempty"`
+}
+
+func (l LightComponent) IsLit() bool {
+	return l.Brightness > 0
+}
+
+// HazardComponent describes what a hazard tile, like a trap or fire, does to creatures on it
+type HazardComponent struct {
+	// Damage done to a creature stepping onto the tile, and to one ending its turn on it
+	StepDamage    int `hcl:"step_damage"`
+	TurnEndDamage int `hcl:"turn_end_damage"`
+
+	// Stamina taken from a creature ending its turn on the tile
+	StaminaDrain int `hcl:"stamina_drain"`
+
+	// Whether stepping onto the tile ends the creature's move there
+	StopsMovement bool `hcl:"stops_movement"`
+
+	// Hidden hazards look like the Disguise tile until a player comes within DetectRange
+	// tiles of them, or something steps on them
+	Hidden      bool
+	Disguise    string
+	DetectRange int `hcl:"detect_range"`
+}
+
+// IsHazard returns whether the component does anything to creatures on the tile
+func (h HazardComponent) IsHazard() bool {
+	return h.StepDamage > 0 || h.TurnEndDamage > 0 || h.StaminaDrain > 0 || h.StopsMovement
+}
+
 type ItemType int
 
 const (
@@ -50,6 +102,7 @@ const (
 	Armor
 	Helm
 	Accessory
+	Consumable
 )
 
 type Item struct {
@@ -70,13 +123,32 @@ type Item struct {
 
 	GrantsIncreasedMeleeRange bool `hcl:"increases_melee_range"`
 
-	Requirements StatComponent `hcl:"reqs"`
-	Bonuses      StatComponent `hcl:"bonus"`
+	Requirements   StatComponent  `hcl:"reqs"`
+	Bonuses        StatComponent  `hcl:"bonus"`
+	PercentBonuses StatComponent  `hcl:"percent_bonus"`
+	Light          LightComponent `hcl:"light"`
+
+	// What a consumable does when it's used, and how many uses it has left
+	Use     UseComponent `hcl:"use"`
+	Charges int
+
+	// How many copies of the item fit in one inventory slot, and how many are in this stack
+	StackSize int `hcl:"stack_size"`
+	Count     int `hcl:"-"`
+
+	// Extra inventory slots the item gives while it's equipped
+	InventorySlots int `hcl:"inventory_slots"`
+
+	// The rarity this particular item was rolled with, and the names of its affixes
+	Rarity  string   `hcl:"-"`
+	Affixes []string `hcl:"-"`
 }
 
 func NewItem(name string, coords GridPoint) *Item {
 	item := GetItemData(name)
 	item.OnGround = true
+	item.Charges = item.MaxCharges()
+	item.Count = 1
 	item.BasicEntity = ecs.NewBasic()
 	item.SpaceComponent = common.SpaceComponent{
 		Position: coords.ToPixels(),
@@ -84,7 +156,7 @@ func NewItem(name string, coords GridPoint) *Item {
 		Height:   TileWidth,
 	}
 	item.RenderComponent = common.RenderComponent{
-		Drawable: Sprites.Cell(item.Icon),
+		Drawable: spriteCell(item.Icon),
 		Scale:    engo.Point{0.5, 0.5},
 	}
 
@@ -94,8 +166,8 @@ func NewItem(name string, coords GridPoint) *Item {
 // GridPoint refers to a specific tile's coordinates; incrementing X by 1
 // translates to going 1 tile to the right
 type GridPoint struct {
-	X int
-	Y int
+	X int `json:"x"`
+	Y int `json:"y"`
 }
 
 func (gp *GridPoint) ToPixels() engo.Point {
@@ -121,9 +193,55 @@ type Tile struct {
 
 	Name  string `hcl:",key"`
 	Icons []int
+
+	// Which of the icons this particular tile was given, and which icon of its disguise it's
+	// shown with if it's a hidden hazard
+	Sprite         int `hcl:"-"`
+	DisguiseSprite int `hcl:"-"`
+
+	// Whether creatures can stand on this tile, and how much movement stepping onto it takes
+	Walkable     bool `hcl:"walkable"`
+	MovementCost int  `hcl:"movement_cost"`
+
+	// Whether this tile stops creatures seeing or light shining through it
+	BlocksSight bool `hcl:"blocks_sight"`
+	BlocksLight bool `hcl:"blocks_light"`
+
+	// The tile this one turns into when it's opened or closed, for doors
+	Toggle string
+
+	// Whether players can take these stairs down to the next floor
+	Stairs bool
+
+	Light  LightComponent  `hcl:"light"`
+	Hazard HazardComponent `hcl:"hazard"`
+
+	// Whether a hidden hazard has been found
+	Detected bool `hcl:"-"`
 }
 
-func NewTile(name string, coords GridPoint) *Tile {
+// Returns the movement it takes to step onto the tile, which is at least 1
+func (t *Tile) GetMovementCost() int {
+	if t.MovementCost < 1 {
+		return 1
+	}
+	return t.MovementCost
+}
+
+// NewTile makes a tile of the given type, using random to pick which of its icons to show.
+// random should be seeded the same way on every client so the map looks the same to everyone.
+func NewTile(name string, coords GridPoint, random *rand.Rand) *Tile {
+	data := GetTileData(name)
+	sprite := data.Icons[random.Intn(len(data.Icons))]
+	disguiseSprite := 0
+	if disguise := GetTileData(data.Hazard.Disguise); data.Hazard.Hidden && len(disguise.Icons) > 0 {
+		disguiseSprite = disguise.Icons[random.Intn(len(disguise.Icons))]
+	}
+	return newTile(name, coords, sprite, disguiseSprite)
+}
+
+// Makes a tile of the given type with the given icons
+func newTile(name string, coords GridPoint, sprite int, disguiseSprite int) *Tile {
 	tile := GetTileData(name)
 	tile.BasicEntity = ecs.NewBasic()
 	tile.SpaceComponent = common.SpaceComponent{
@@ -131,18 +249,38 @@ func NewTile(name string, coords GridPoint) *Tile {
 		Width:    TileWidth,
 		Height:   TileWidth,
 	}
-	sprite := tile.Icons[rand.Intn(len(tile.Icons))]
+	tile.Sprite = sprite
+	tile.DisguiseSprite = disguiseSprite
 	tile.RenderComponent = common.RenderComponent{
-		Drawable: Sprites.Cell(sprite),
+		Drawable: spriteCell(tile.Sprite),
 		Color:    color.Alpha{MinBrightness},
 		Scale:    engo.Point{1, 1},
 	}
 	tile.RenderComponent.SetZIndex(-100)
 	tile.GridPoint = coords
 
+	// Hidden hazards are drawn as their disguise until they're found
+	if tile.Hazard.Hidden && disguiseSprite != 0 {
+		tile.RenderComponent.Drawable = spriteCell(disguiseSprite)
+	}
+
 	return &tile
 }
 
+// IsHidden returns whether the tile is a hidden hazard that hasn't been found yet
+func (t *Tile) IsHidden() bool {
+	return t.Hazard.Hidden && !t.Detected
+}
+
+// Reveal marks a hidden hazard as found, showing its real icon
+func (t *Tile) Reveal() {
+	if !t.IsHidden() {
+		return
+	}
+	t.Detected = true
+	t.RenderComponent.Drawable = spriteCell(t.Sprite)
+}
+
 type Skill struct {
 	Name string `hcl:",key"`
 
@@ -159,6 +297,12 @@ type Skill struct {
 
 	Effects map[string]int
 
+	// How many tiles away enemies can hear the skill being used, 0 to use the default
+	Noise int
+
+	// Light given off at the target location until the end of the turn
+	Light LightComponent `hcl:"light"`
+
 	Tags []string
 }
 
@@ -182,6 +326,7 @@ const PierceEffect = "pierces"
 const AoeEffect = "aoe_radius"
 
 const MeleeTag = "melee"
+const HealTag = "heal"
 
 type SkillTarget struct {
 	ID       NetworkID
@@ -0,0 +1,61 @@
+package structs
+
+import "math/rand"
+
+// RoomTheme decides what gets spawned in a room when a floor of the dungeon is generated
+type RoomTheme struct {
+	Name string `hcl:",key"`
+
+	// How likely this theme is to be picked compared to the others
+	Weight int
+
+	// How many creatures and items to spawn, plus a random amount up to the extra. Rooms
+	// with creatures get one more for each floor below the first.
+	Creatures      int
+	ExtraCreatures int `hcl:"extra_creatures"`
+	Items          int
+	ExtraItems     int `hcl:"extra_items"`
+
+	// How many hazard tiles to put down, plus a random amount up to the extra
+	Hazards      int
+	ExtraHazards int `hcl:"extra_hazards"`
+
+	// Which creatures, items and hazard tiles get spawned
+	CreatureTable SpawnTable `hcl:"creature"`
+	ItemTable     SpawnTable `hcl:"item"`
+	HazardTable   SpawnTable `hcl:"hazard"`
+
+	// A light placed in the middle of the room, if it has a brightness
+	Light LightComponent `hcl:"light"`
+}
+
+// SpawnEntry is something that can be picked from a spawn table
+type SpawnEntry struct {
+	Name string `hcl:",key"`
+
+	// How likely the entry is to be picked compared to the others in the table
+	Weight int
+}
+
+// SpawnTable is a list of things to pick from at random, weighted by how common they are
+type SpawnTable []SpawnEntry
+
+// Roll picks an entry from the table, or returns an empty string if the table is empty
+func (t SpawnTable) Roll(random *rand.Rand) string {
+	total := 0
+	for _, entry := range t {
+		total += entry.Weight
+	}
+	if total <= 0 {
+		return ""
+	}
+
+	roll := random.Intn(total)
+	for _, entry := range t {
+		if roll < entry.Weight {
+			return entry.Name
+		}
+		roll -= entry.Weight
+	}
+	return ""
+}